@@ -0,0 +1,43 @@
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/Zarone/CardGameServer/cmd/gamemanager"
+)
+
+// Checks the card sets in a card info directory, printing every
+// problem found in them and exiting with status 1 if there are any
+//
+// example: go run ./cmd/cardcheck ./cardInfo
+func main() {
+  flag.Usage = func() {
+    fmt.Fprintln(flag.CommandLine.Output(), "usage: cardcheck [card info directory]")
+  }
+  flag.Parse()
+
+  path := "./cardInfo"
+  if flag.NArg() > 0 {
+    path = flag.Arg(0)
+  }
+
+  cardHandler, err := gamemanager.LoadFromDirectory(path)
+  if cardHandler == nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+
+  problems := gamemanager.SplitErrors(errors.Join(err, cardHandler.Validate()))
+  for _, problem := range problems {
+    fmt.Println(problem)
+  }
+
+  if len(problems) > 0 {
+    fmt.Printf("%d problems found in %s\n", len(problems), path)
+    os.Exit(1)
+  }
+  fmt.Printf("No problems found in %s\n", path)
+}
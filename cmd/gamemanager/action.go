@@ -6,8 +6,12 @@ type Action struct {
   ActionType    ActionType  `json:"type"`
   SelectedCards []uint      `json:"selectedCards"`
   From          Pile        `json:"from"`
+
+  // if ActionType=ActionTypeChooseOption, the index 
+  // of the chosen branch
+  Option        int         `json:"option"`
 }
 
 func (a *Action) String() string {
-  return fmt.Sprintf("{ActionType: %v, SelectedCards: %v, From: %v}\n", a.ActionType, a.SelectedCards, a.From)
+  return fmt.Sprintf("{ActionType: %v, SelectedCards: %v, From: %v, Option: %d}\n", a.ActionType, a.SelectedCards, a.From, a.Option)
 }
@@ -7,3 +7,12 @@ type CardActionStack struct {
   incitingAction *Action
 }
 
+// Returns the innermost entry of the stack, which is the 
+// effect waiting on input from the player
+func (s *CardActionStack) pending() *CardActionStack {
+  entry := s
+  for entry != nil && entry.inner != nil {
+    entry = entry.inner
+  }
+  return entry
+}
@@ -1,5 +1,52 @@
 package gamemanager
 
+// The names card data can use, which the game code and
+// the validator both go by
+
+const (
+  ExpressionConstant = "CONSTANT"
+  ExpressionVariable = "VARIABLE"
+  ExpressionOperator = "OPERATOR"
+)
+
+const (
+  OperatorGreaterThan = ">"
+)
+
+const (
+  VariableCardsInHand = "CARDS_IN_HAND"
+)
+
+const (
+  EffectThen     = "THEN"
+  EffectOr       = "OR"
+  EffectMove     = "MOVE"
+  EffectShuffle  = "SHUFFLE"
+  EffectTarget   = "TARGET"
+  EffectWin      = "WIN"
+  EffectLose     = "LOSE"
+  EffectDrawGame = "DRAW_GAME"
+)
+
+const (
+  TargetSelect = "SELECT"
+  TargetAll    = "ALL"
+  TargetThis   = "THIS"
+)
+
+const (
+  FilterAnd  = "AND"
+  FilterOr   = "OR"
+  FilterJust = "JUST"
+)
+
+const (
+  CardTypeBasicCharacter   = "BASIC_CHARACTER"
+  CardTypeSpecialCharacter = "SPECIAL_CHARACTER"
+  CardTypeEvent            = "EVENT"
+  CardTypeAction           = "ACTION"
+)
+
 type Expression struct {
 	Kind  string  `json:"kind"` // "CONSTANT", "VARIABLE", "OPERATOR"
 
@@ -15,15 +62,29 @@ type Expression struct {
 }
 
 type CardEffect struct {
-  Kind  string  `json:"kind"` // THEN, OR, MOVE, SHUFFLE, TARGET
+  Kind  string  `json:"kind"` // THEN, OR, MOVE, SHUFFLE, TARGET, WIN, LOSE, DRAW_GAME
+
+  // Always Optional, shown to the player when this 
+  // effect is a branch of an "OR"
+  Label string  `json:"label,omitempty"`
+
+  // Always Optional, a branch of an "OR" is only offered
+  // if this evaluates to true
+  PreCondition *Expression `json:"preCondition,omitempty"`
 
   // if Kind="THEN" or KIND="OR"
   Args  []*CardEffect `json:"args,omitempty"`
 
-  // if Kind="MOVE"
+  // if Kind="MOVE" or Kind="SHUFFLE"
   CardTarget *CardEffect `json:"target,omitempty"`
+
+  // if Kind="MOVE"
   To    string  `json:"to,omitempty"`
 
+  // if Kind="SHUFFLE", shuffles this pile rather than
+  // the piles containing the target cards
+  Pile  string  `json:"pile,omitempty"`
+
   // if Kind="TARGET"
   TargetType string `json:"targetType,omitempty"` // SELECT, ALL, THIS
   Filter CardFilter `json:"filter,omitempty"`
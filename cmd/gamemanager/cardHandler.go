@@ -2,17 +2,51 @@ package gamemanager
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
-type Alias struct {
+// The set cards are taken from when no set is given
+const DefaultCardSet = "set1"
+
+// Identifies a card by the set it's from and its number in that set
+type CardIdentity struct {
   Set string  `json:"set"`
   ID  uint    `json:"id"`
 }
 
+func (c CardIdentity) String() string {
+  return fmt.Sprintf("%s/%d", c.Set, c.ID)
+}
+
+// Also accepts a bare card number, which older clients send,
+// as that card in the default set
+func (c *CardIdentity) UnmarshalJSON(data []byte) error {
+  var id uint
+  if err := json.Unmarshal(data, &id); err == nil {
+    *c = CardIdentity{Set: DefaultCardSet, ID: id}
+    return nil
+  }
+
+  type TMP CardIdentity
+  return json.Unmarshal(data, (*TMP)(c))
+}
+
+// Builds a deck of the given card numbers from one set
+func DeckFromSet(set string, ids ...uint) []CardIdentity {
+  deck := make([]CardIdentity, 0, len(ids))
+  for _, id := range ids {
+    deck = append(deck, CardIdentity{Set: set, ID: id})
+  }
+  return deck
+}
+
+type Alias = CardIdentity
+
 type StaticCardDataRaw struct {
   Name          string      `json:"name,omitempty"`
   ImageSrc      string      `json:"imageSrc"`
@@ -46,53 +80,126 @@ type StaticCardData struct {
 
 type CardHandler struct {
   cardLookup map[string]([]StaticCardData)
+  rules      GameRules
+  // Counts up each time the cards are reloaded, 
+  // so games can tell which cards they're using
+  Version    uint
 }
 
 // Takes a string representing the available cards and returns a 
-// cardHandler with set1 set to those cards
+// cardHandler with the default set set to those cards
 func SetupFromString(content string) *CardHandler {
+	cardHandler, err := LoadFromString(content)
+	if err != nil {
+		fmt.Printf("Error processing string content: %v\n", err)
+	}
+	return cardHandler
+}
+
+// Like SetupFromString, but returns the problems with the cards
+// rather than printing them. The cardHandler is still usable
+// alongside an error, without the cards that couldn't be loaded.
+func LoadFromString(content string) (*CardHandler, error) {
 	cardHandler := &CardHandler{
 		cardLookup: make(map[string][]StaticCardData, 1),
+		rules: DefaultGameRules(),
 	}
 	rawLookupTables := make(map[string][]StaticCardDataRaw)
 
-	if err := processSet("set1", []byte(content), cardHandler, rawLookupTables); err != nil {
-		fmt.Printf("Error processing string content: %v\n", err)
+	if err := processSet(DefaultCardSet, []byte(content), cardHandler, rawLookupTables); err != nil {
+		return cardHandler, err
 	}
 
-	resolveAliases(cardHandler, rawLookupTables)
-	return cardHandler
+	return cardHandler, resolveAliases(cardHandler, rawLookupTables)
+}
+
+// Returns the data of the card with the given identity, which
+// already includes whatever it takes from the card it's an alias of
+func (ch *CardHandler) Lookup(identity CardIdentity) (*StaticCardData, error) {
+  set, ok := ch.cardLookup[identity.Set]
+  if !ok {
+    return nil, newGameError(ErrorCodeUnknownCard, "unknown card set %q", identity.Set)
+  }
+  if identity.ID >= uint(len(set)) {
+    return nil, newGameError(ErrorCodeUnknownCard, "no card %d in set %q", identity.ID, identity.Set)
+  }
+  return &set[identity.ID], nil
 }
 
 // Takes a directory path and returns a cardHandler generated from the
 // text files contained in it
 func SetupFromDirectory(path string) *CardHandler {
+	cardHandler, err := LoadFromDirectory(path)
+	if cardHandler == nil {
+		log.Fatal(err)
+	}
+	if err != nil {
+		fmt.Printf("Error loading cards from %s:\n%v\n", path, err)
+	}
+	return cardHandler
+}
+
+// Like SetupFromDirectory, but returns the problems with the files
+// rather than printing them. Unless the directory can't be read, 
+// the cardHandler is still usable alongside an error, without 
+// whatever couldn't be loaded.
+func LoadFromDirectory(path string) (*CardHandler, error) {
 	entries, err := os.ReadDir(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	cardHandler := &CardHandler{
 		cardLookup: make(map[string][]StaticCardData, len(entries)),
+		rules: DefaultGameRules(),
 	}
 	rawLookupTables := make(map[string][]StaticCardDataRaw)
 
+	var errs []error
 	for _, e := range entries {
 		fileName := e.Name()
 		text, err := os.ReadFile(path + "/" + fileName)
 		if err != nil {
-			fmt.Printf("Error reading file %s: %v\n", fileName, err)
+			errs = append(errs, fmt.Errorf("error reading file %s: %w", fileName, err))
+			continue
+		}
+
+		if fileName == RulesFileName {
+			rules, err := parseRules(text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error processing rules from file %s: %w", fileName, err))
+				continue
+			}
+			cardHandler.rules = rules
 			continue
 		}
 
 		setName := strings.Split(fileName, ".")[0]
 		if err := processSet(setName, text, cardHandler, rawLookupTables); err != nil {
-			fmt.Printf("Error processing set from file %s: %v\n", fileName, err)
+			errs = append(errs, fmt.Errorf("error processing set from file %s: %w", fileName, err))
 		}
 	}
 
-	resolveAliases(cardHandler, rawLookupTables)
-	return cardHandler
+	errs = append(errs, resolveAliases(cardHandler, rawLookupTables))
+	return cardHandler, errors.Join(errs...)
+}
+
+// Splits errors joined by the loader or Validate back 
+// into each problem found
+func SplitErrors(err error) []error {
+  if err == nil {
+    return nil
+  }
+  joined, ok := err.(interface{ Unwrap() []error })
+  if !ok {
+    return []error{err}
+  }
+
+  errs := make([]error, 0)
+  for _, inner := range joined.Unwrap() {
+    errs = append(errs, SplitErrors(inner)...)
+  }
+  return errs
 }
 
 // processSet handles unmarshalling and initial processing of a single set.
@@ -119,23 +226,80 @@ func processSet(setName string, content []byte, ch *CardHandler, rawLookups map[
 	return nil
 }
 
-// resolveAliases populates the Alias fields after all cards have been loaded.
-func resolveAliases(ch *CardHandler, rawLookups map[string][]StaticCardDataRaw) {
-	for setName, rawLookupTable := range rawLookups {
-		for index, element := range rawLookupTable {
-			if element.Alias.Set == "" {
-				continue
-			}
-			if _, ok := ch.cardLookup[element.Alias.Set]; !ok {
-				fmt.Println("Loading in card with alias pointing to unknown set", element.Alias.Set)
-				continue
-			}
-			if uint(len(ch.cardLookup[element.Alias.Set])) <= element.Alias.ID {
-				fmt.Println("Loading in card with alias pointing to valid set but unknown id", element.Alias.ID)
-				continue
+// resolveAliases fills in whatever each card with an alias leaves
+// unset from the card it's an alias of, once all cards have been
+// loaded, following chains of aliases. Returns an error for every
+// alias that's part of a cycle or points to a card that doesn't exist.
+func resolveAliases(ch *CardHandler, rawLookups map[string][]StaticCardDataRaw) error {
+	setNames := make([]string, 0, len(rawLookups))
+	for setName := range rawLookups {
+		setNames = append(setNames, setName)
+	}
+	// so the errors come out in the same order every time
+	sort.Strings(setNames)
+
+	resolved := make(map[CardIdentity]bool)
+	var errs []error
+	for _, setName := range setNames {
+		for index := range rawLookups[setName] {
+			card := CardIdentity{Set: setName, ID: uint(index)}
+			if err := resolveAlias(ch, rawLookups, card, resolved, nil); err != nil {
+				errs = append(errs, err)
 			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-			ch.cardLookup[setName][index].Alias = &ch.cardLookup[element.Alias.Set][element.Alias.ID]
+// resolveAlias resolves the card after the card it's an alias of, 
+// where chain holds the cards whose aliases led to this one. An error
+// is only returned by the first card to find it.
+func resolveAlias(ch *CardHandler, rawLookups map[string][]StaticCardDataRaw, card CardIdentity, resolved map[CardIdentity]bool, chain []CardIdentity) error {
+	if resolved[card] {
+		return nil
+	}
+	for index, seen := range chain {
+		if seen == card {
+			cycle := ""
+			for _, element := range chain[index:] {
+				cycle += element.String() + " -> "
+			}
+			return fmt.Errorf("alias cycle %s%s", cycle, card)
 		}
 	}
+	defer func() { resolved[card] = true }()
+
+	alias := rawLookups[card.Set][card.ID].Alias
+	if alias.Set == "" {
+		return nil
+	}
+	target, err := ch.Lookup(alias)
+	if err != nil {
+		return fmt.Errorf("card %s is an alias of %s, which doesn't exist", card, alias)
+	}
+
+	if err := resolveAlias(ch, rawLookups, alias, resolved, append(chain, card)); err != nil {
+		return err
+	}
+
+	ch.cardLookup[card.Set][card.ID].inherit(target)
+	return nil
+}
+
+// Fills in every field the card leaves unset from the card it's an 
+// alias of, other than its image, which every printing has its own of
+func (cd *StaticCardData) inherit(target *StaticCardData) {
+	cd.Alias = target
+	if cd.Name == "" {
+		cd.Name = target.Name
+	}
+	if cd.PreCondition == nil {
+		cd.PreCondition = target.PreCondition
+	}
+	if cd.Effect == nil {
+		cd.Effect = target.Effect
+	}
+	if cd.CardType == "" {
+		cd.CardType = target.CardType
+	}
 }
@@ -1,9 +1,15 @@
 package gamemanager
 
 type CardMovement struct {
-  GameID  uint `json:"gameId"`
-  CardID  uint `json:"cardId"`
-  From    Pile `json:"from"`
-  To      Pile `json:"to"`
+  GameID  uint    `json:"gameId"`
+  CardID  uint    `json:"cardId"`
+  // Empty along with CardID when the card is hidden
+  Set     string  `json:"set,omitempty"`
+  From    Pile    `json:"from"`
+  To      Pile    `json:"to"`
+}
+
+func (m CardMovement) Identity() CardIdentity {
+  return CardIdentity{Set: m.Set, ID: m.CardID}
 }
 
@@ -6,12 +6,18 @@ import (
 )
 
 type Card struct {
+  Set    string
   ID     uint
   GameID uint
 }
 
 func (c Card) String() string {
-  return fmt.Sprintf("[ID: %d, GameID: %d]", c.ID, c.GameID)
+  return fmt.Sprintf("[Set: %s, ID: %d, GameID: %d]", c.Set, c.ID, c.GameID)
+}
+
+// Returns which card this is, regardless of which copy
+func (c Card) Identity() CardIdentity {
+  return CardIdentity{Set: c.Set, ID: c.ID}
 }
 
 type CardGroup struct {
@@ -20,8 +26,8 @@ type CardGroup struct {
   PublicKnowledge bool
 }
 
-func (cg *CardGroup) shuffle() {
-  rand.Shuffle(len(cg.Cards), func(i, j int) {
+func (cg *CardGroup) shuffle(seed int64) {
+  rand.New(rand.NewSource(seed)).Shuffle(len(cg.Cards), func(i, j int) {
     (cg.Cards)[i], (cg.Cards)[j] = (cg.Cards)[j], (cg.Cards)[i]
   })
 }
@@ -51,3 +57,12 @@ func (cg *CardGroup) findCard (gameID uint) (Card, int) {
   }
   return Card{}, -1
 }
+
+// Returns the top (the end) "numberOfCards" cards of the group,
+// or every card if the group has fewer
+func (cg *CardGroup) top(numberOfCards int) []Card {
+  if numberOfCards >= len(cg.Cards) {
+    return cg.Cards
+  }
+  return cg.Cards[len(cg.Cards)-numberOfCards:]
+}
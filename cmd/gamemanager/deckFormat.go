@@ -0,0 +1,128 @@
+package gamemanager
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Rules for building decks, checked before a game starts.
+// Anything left at zero isn't restricted
+type DeckFormat struct {
+  MinSize     int       `json:"minSize,omitempty"`
+  MaxSize     int       `json:"maxSize,omitempty"`
+  // The most copies of one card a deck can have, with reprints
+  // counting as copies of the card they're an alias of
+  CopyLimit   int       `json:"copyLimit,omitempty"`
+  // The sets cards can be taken from, or any set if it's empty
+  AllowedSets []string  `json:"allowedSets,omitempty"`
+}
+
+// Returns an UNKNOWN_CARD error if the deck has cards that
+// don't exist, an ILLEGAL_DECK error listing every way the
+// deck breaks the game's deck format, or nil if it's legal
+func (g *Game) CheckDeck(deck []CardIdentity) error {
+  return g.CardHandler.CheckDeck(g.Rules.DeckFormat, deck)
+}
+
+// Returns an UNKNOWN_CARD error if the deck has cards that
+// don't exist, an ILLEGAL_DECK error listing every way the
+// deck breaks the format, or nil if it's legal
+func (ch *CardHandler) CheckDeck(format DeckFormat, deck []CardIdentity) error {
+  if err := ch.CheckCardsExist(deck); err != nil {
+    return err
+  }
+
+  violations := make([]string, 0)
+
+  if format.MinSize > 0 && len(deck) < format.MinSize {
+    violations = append(violations, fmt.Sprintf("deck has %d cards, fewer than the minimum of %d", len(deck), format.MinSize))
+  }
+  if format.MaxSize > 0 && len(deck) > format.MaxSize {
+    violations = append(violations, fmt.Sprintf("deck has %d cards, more than the maximum of %d", len(deck), format.MaxSize))
+  }
+
+  // counted by the original of each card, in the order
+  // they first show up in the deck
+  copies := make(map[*StaticCardData]int)
+  originals := make([]*StaticCardData, 0)
+  names := make(map[*StaticCardData]string)
+  reported := make(map[CardIdentity]bool)
+  for _, card := range deck {
+    if !format.allowsSet(card.Set) {
+      if !reported[card] {
+        reported[card] = true
+        violations = append(violations, fmt.Sprintf("%s is from %s, which isn't allowed", card, card.Set))
+      }
+      continue
+    }
+
+    data, err := ch.Lookup(card)
+    if err != nil {
+      return err
+    }
+
+    original := data.original()
+    if copies[original] == 0 {
+      originals = append(originals, original)
+      names[original] = original.Name
+      if names[original] == "" {
+        names[original] = card.String()
+      }
+    }
+    copies[original]++
+  }
+
+  if format.CopyLimit > 0 {
+    for _, original := range originals {
+      if copies[original] > format.CopyLimit {
+        violations = append(violations, fmt.Sprintf("deck has %d copies of %s, more than the limit of %d", copies[original], names[original], format.CopyLimit))
+      }
+    }
+  }
+
+  if len(violations) == 0 {
+    return nil
+  }
+  return newGameError(ErrorCodeIllegalDeck, "%s", strings.Join(violations, "; "))
+}
+
+// Returns an UNKNOWN_CARD error listing every card in the
+// deck that doesn't exist, or nil if they all do
+func (ch *CardHandler) CheckCardsExist(deck []CardIdentity) error {
+  unknown := make([]string, 0)
+  reported := make(map[CardIdentity]bool)
+  for _, card := range deck {
+    if reported[card] {
+      continue
+    }
+    if _, err := ch.Lookup(card); err != nil {
+      reported[card] = true
+      unknown = append(unknown, ToGameError(err).Message)
+    }
+  }
+
+  if len(unknown) == 0 {
+    return nil
+  }
+  return newGameError(ErrorCodeUnknownCard, "%s", strings.Join(unknown, "; "))
+}
+
+func (format DeckFormat) allowsSet(set string) bool {
+  if len(format.AllowedSets) == 0 {
+    return true
+  }
+  for _, allowed := range format.AllowedSets {
+    if set == allowed {
+      return true
+    }
+  }
+  return false
+}
+
+// Returns the card at the end of this card's chain of aliases
+func (cd *StaticCardData) original() *StaticCardData {
+  for cd.Alias != nil {
+    cd = cd.Alias
+  }
+  return cd
+}
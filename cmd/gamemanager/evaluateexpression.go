@@ -7,13 +7,13 @@ import (
 
 func (g *Game) getGameVariable(user uint8, varName string) (*Expression, error) {
   switch varName {
-  case "CARDS_IN_HAND":
+  case VariableCardsInHand:
     hand, ok := g.Players[user].PlayerPiles[HAND_PILE]
     if !ok {
       return nil, errors.New("Could not get hand")
     }
     return &Expression{
-      Kind: "CONSTANT",
+      Kind: ExpressionConstant,
       Val: len(hand.Cards),
     }, nil
   default:
@@ -21,11 +21,16 @@ func (g *Game) getGameVariable(user uint8, varName string) (*Expression, error)
   }
 }
 
+// The number of arguments each operator takes
+var operatorArgCounts = map[string]int{
+  OperatorGreaterThan: 2,
+}
+
 func (g *Game) evaluateOperator(user uint8, expression *Expression) (*Expression, error) {
   switch expression.Operator {
-  case ">":
+  case OperatorGreaterThan:
     numArgs := len(expression.Args)
-    if numArgs != 2 {
+    if numArgs != operatorArgCounts[OperatorGreaterThan] {
       return nil, fmt.Errorf("Expected 2 arguments to \">\", but received %d\n", numArgs) 
     }
     left, err := g.evaluateToConstant(user, expression.Args[0])
@@ -37,7 +42,7 @@ func (g *Game) evaluateOperator(user uint8, expression *Expression) (*Expression
     if left.Val > right.Val { val = 1 }
 
     return &Expression{
-      Kind: "CONSTANT",
+      Kind: ExpressionConstant,
       Val: val,
     }, nil
   default:
@@ -47,11 +52,11 @@ func (g *Game) evaluateOperator(user uint8, expression *Expression) (*Expression
 
 func (g *Game) evaluateToConstant(user uint8, expression *Expression) (*Expression, error) {
   switch expression.Kind {
-  case "CONSTANT":
+  case ExpressionConstant:
     return expression, nil
-  case "VARIABLE":
+  case ExpressionVariable:
     return g.getGameVariable(user, expression.Variable)
-  case "OPERATOR":
+  case ExpressionOperator:
     return g.evaluateOperator(user, expression)    
   default:
     return nil, fmt.Errorf("UNKNOWN EXPRESSION KIND: %s\n", expression.Kind)    
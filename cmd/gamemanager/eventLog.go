@@ -0,0 +1,180 @@
+package gamemanager
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+)
+
+type EventKind string
+
+const (
+  EventKindSetup      = EventKind("SETUP")
+  EventKindShuffle    = EventKind("SHUFFLE")
+  EventKindCoinFlip   = EventKind("COIN_FLIP")
+  EventKindStart      = EventKind("START")
+  EventKindAction     = EventKind("ACTION")
+)
+
+// A change to the game's state. Only the fields for its
+// kind are set
+type Event struct {
+  Kind        EventKind       `json:"kind"`
+  Player      uint8           `json:"player"`
+
+  // SETUP: the card IDs of the player's deck
+  Deck        []CardIdentity  `json:"deck,omitempty"`
+
+  // SHUFFLE: the player's pile that was shuffled, and the 
+  // seed it was shuffled with. START: the game's seed
+  Pile        Pile            `json:"pile,omitempty"`
+  Seed        int64           `json:"seed,omitempty"`
+
+  // COIN_FLIP: whether the first player called heads, and
+  // whether the coin landed on heads
+  CalledHeads bool            `json:"calledHeads,omitempty"`
+  Heads       bool            `json:"heads,omitempty"`
+
+  // START: whether the first player went first
+  GoingFirst  bool            `json:"goingFirst,omitempty"`
+
+  // START and ACTION: every card movement caused, from the 
+  // first player's point of view with no card hidden
+  Movements   []CardMovement  `json:"movements,omitempty"`
+
+  // ACTION: the player's action, and the result if it 
+  // ended the game
+  Action      *Action         `json:"action,omitempty"`
+  Result      *GameResult     `json:"result,omitempty"`
+}
+
+func (g *Game) record(event Event) {
+  g.Log = append(g.Log, event)
+}
+
+// Records how the coin flip deciding who chooses the turn
+// order went. The flip happens outside of the game, so it's
+// only recorded for anyone reading the log
+func (g *Game) RecordCoinFlip(calledHeads bool, heads bool) {
+  g.record(Event{
+    Kind: EventKindCoinFlip,
+    CalledHeads: calledHeads,
+    Heads: heads,
+  })
+}
+
+// Shuffles the player's pile with a fresh seed, and records
+// the seed so the shuffle can be replayed
+func (g *Game) shuffle(player uint8, group *CardGroup) {
+  seed := g.rng.Int63()
+  group.shuffle(seed)
+  g.record(Event{
+    Kind: EventKindShuffle,
+    Player: player,
+    Pile: group.Pile,
+    Seed: seed,
+  })
+}
+
+// Records the action, with the movements it caused going 
+// by the updates sent to the players
+func (g *Game) recordAction(user uint8, action *Action, info *UpdateInfo, oppInfo *UpdateInfo) {
+  firstPlayerInfo := info
+  if user != 0 {
+    firstPlayerInfo = oppInfo
+  }
+
+  recorded := *action
+  g.record(Event{
+    Kind: EventKindAction,
+    Player: user,
+    Action: &recorded,
+    Movements: g.ToOmniscientInfo(firstPlayerInfo).Movements,
+    Result: g.Result,
+  })
+}
+
+// Records an action that ended the game by failing partway
+// through, which has no movements to go by
+func (g *Game) recordFailedAction(user uint8, action *Action) {
+  recorded := *action
+  g.record(Event{
+    Kind: EventKindAction,
+    Player: user,
+    Action: &recorded,
+    Result: g.Result,
+  })
+}
+
+// Rebuilds a game from its event log, and checks that every 
+// shuffle, movement and result comes out the same as it was
+// recorded
+func Replay(cardHandler *CardHandler, log []Event) (*Game, error) {
+  g := MakeGame(cardHandler)
+
+  for index, event := range log {
+    switch event.Kind {
+    case EventKindSetup:
+      for int(event.Player) >= len(g.Players) {
+        g.AddPlayer()
+      }
+      if err := g.SetupPlayer(event.Player, event.Deck); err != nil {
+        return g, fmt.Errorf("event %d: %w", index, err)
+      }
+    case EventKindShuffle:
+      // replayed along with whatever caused it, and
+      // checked against the log afterwards
+      continue
+    case EventKindCoinFlip:
+      g.RecordCoinFlip(event.CalledHeads, event.Heads)
+    case EventKindStart:
+      if len(g.Players) != 2 {
+        return g, fmt.Errorf("event %d: game started with %d players", index, len(g.Players))
+      }
+      // the game's seed gives the same shuffles as before
+      g.SetSeed(event.Seed)
+      g.StartGame(event.GoingFirst)
+    case EventKindAction:
+      if event.Action == nil {
+        return g, fmt.Errorf("event %d: action event has no action", index)
+      }
+      _, _, err := g.ProcessAction(event.Player, event.Action)
+      failedAsLogged := event.Result != nil && event.Result.Reason == EndReasonError && !IsGameError(err)
+      if err != nil && !failedAsLogged {
+        return g, fmt.Errorf("event %d: action was rejected: %w", index, err)
+      }
+    default:
+      return g, fmt.Errorf("event %d: unknown event kind %s", index, event.Kind)
+    }
+
+    if err := checkReplayedEvents(log, g.Log); err != nil {
+      return g, err
+    }
+  }
+
+  if len(g.Log) != len(log) {
+    return g, fmt.Errorf("replay recorded %d events, but the log has %d", len(g.Log), len(log))
+  }
+
+  return g, nil
+}
+
+// Returns an error if the replayed events differ from the
+// start of the log
+func checkReplayedEvents(log []Event, replayed []Event) error {
+  if len(replayed) > len(log) {
+    return fmt.Errorf("replay recorded %d events, but the log only has %d", len(replayed), len(log))
+  }
+
+  for index, event := range replayed {
+    expected, err := json.Marshal(log[index])
+    if err != nil { return err }
+    actual, err := json.Marshal(event)
+    if err != nil { return err }
+
+    if !bytes.Equal(expected, actual) {
+      return fmt.Errorf("event %d: replay diverged, expected %s, got %s", index, expected, actual)
+    }
+  }
+  return nil
+}
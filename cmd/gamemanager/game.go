@@ -3,10 +3,12 @@ package gamemanager
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 )
 
 type StaticPileData struct {
   publicKnowledge bool
+  ownerKnowledge  bool
 }
 
 type Game struct {
@@ -15,20 +17,49 @@ type Game struct {
   CardHandler     *CardHandler
   CardActionStack *CardActionStack
   PerPlayerPiles  map[Pile]*StaticPileData
+  ActivePlayer    uint8
+  TurnNumber      uint
+  Rules           GameRules
+  Result          *GameResult
+
+  // Every change to the game's state, in order
+  Log             []Event
+
+  // The seed of the random source used for every shuffle
+  Seed            int64
+  rng             *rand.Rand
+}
+
+// Returns the piles each player has
+func perPlayerPiles() map[Pile]*StaticPileData {
+  return map[Pile]*StaticPileData{
+    HAND_PILE: {publicKnowledge: false, ownerKnowledge: true}, 
+    DECK_PILE: {publicKnowledge: false, ownerKnowledge: false}, 
+    DISCARD_PILE: {publicKnowledge: true, ownerKnowledge: true}, 
+  }
 }
 
 func MakeGame(cardHandler *CardHandler) *Game {
-	return &Game{
+	g := &Game{
 		CardIndex: 0,
 		Players: make([]Player, 0, 2),
     CardHandler: cardHandler,
     CardActionStack: nil,
-    PerPlayerPiles: map[Pile]*StaticPileData{
-      HAND_PILE: {publicKnowledge: false}, 
-      DECK_PILE: {publicKnowledge: false}, 
-      DISCARD_PILE: {publicKnowledge: true}, 
-    },
+    Rules: cardHandler.rules,
+    Result: nil,
+    Log: make([]Event, 0),
+    PerPlayerPiles: perPlayerPiles(),
 	}
+	g.SetSeed(rand.Int63())
+	return g
+}
+
+// Seeds the random source of the game, so that the same
+// seed always gives the same shuffles. Has to be called 
+// before the game starts to take effect
+func (g *Game) SetSeed(seed int64) {
+  g.Seed = seed
+  g.rng = rand.New(rand.NewSource(seed))
 }
 
 // returns the index of this player within
@@ -40,22 +71,37 @@ func (g *Game) AddPlayer() uint8 {
 }
 
 // Sets up player with the given playerID with the deck given by 
-// an array of the card IDs.
-func (g *Game) SetupPlayer(playerID uint8, deck []uint) {
+// an array of card identities. Returns an error, leaving the
+// player as they were, if any of the cards don't exist.
+func (g *Game) SetupPlayer(playerID uint8, deck []CardIdentity) error {
 	var player *Player = &g.Players[playerID]
 
   playerDeck, ok := g.Players[playerID].PlayerPiles[DECK_PILE]
-  if !ok { fmt.Println("Could not find deck pile"); return }
+  if !ok { return errors.New("Could not find deck pile") }
+
+  for _, el := range deck {
+    if _, err := g.CardHandler.Lookup(el); err != nil {
+      return err
+    }
+  }
 
 	playerDeck.Cards = make([]Card, 0, len(deck))
 	for _, el := range deck {
 		playerDeck.Cards = append(playerDeck.Cards, Card{
-			ID: el,
+			Set: el.Set,
+			ID: el.ID,
 			GameID: g.CardIndex,
 		})
     player.FindID[g.CardIndex] = playerDeck
 		g.CardIndex++
 	}
+
+  g.record(Event{
+    Kind: EventKindSetup,
+    Player: playerID,
+    Deck: append([]CardIdentity(nil), deck...),
+  })
+  return nil
 }
 
 // Takes cardIDs, and returns the corresponding game IDs
@@ -99,8 +145,15 @@ func (g *Game) StartGame(goingFirst bool) (*UpdateInfo, *UpdateInfo) {
   p2Hand, ok := g.Players[1].PlayerPiles[HAND_PILE]
   if !ok { fmt.Println("Could not find hand pile"); return nil, nil }
 
-	p1Deck.shuffle()
-	p2Deck.shuffle()
+  if goingFirst {
+    g.ActivePlayer = 0
+  } else {
+    g.ActivePlayer = 1
+  }
+  g.TurnNumber = 1
+
+	g.shuffle(0, p1Deck)
+	g.shuffle(1, p2Deck)
   fmt.Println(g.Players[0], g.Players[1])
   p1Moves, p2Moves := g.Players[0].moveFromTopTo(p1Deck, p1Hand, 7), 
 		g.Players[1].moveFromTopTo(p2Deck, p2Hand, 7)
@@ -139,15 +192,79 @@ func (g *Game) StartGame(goingFirst bool) (*UpdateInfo, *UpdateInfo) {
     SelectableCards: selectableCards,
   }
 
+  g.record(Event{
+    Kind: EventKindStart,
+    Seed: g.Seed,
+    GoingFirst: goingFirst,
+    Movements: g.ToOmniscientInfo(&out1).Movements,
+  })
+
 	return &out1, &out2
 }
 
 func (g *Game) ProcessAction(user uint8, action *Action) (*UpdateInfo, *UpdateInfo, error) {
-  if (ActionType(action.ActionType) == ActionTypeSelectCard) {
+  if g.IsOver() {
+    return nil, nil, newGameError(ErrorCodeGameOver, "the game is over")
+  }
+
+  info, oppInfo, err := g.processAction(user, action)
+  if err != nil && !IsGameError(err) {
+    // the action may have been partly applied, so the
+    // game can't be trusted to go on
+    g.CardActionStack = nil
+    g.draw(EndReasonError)
+    g.recordFailedAction(user, action)
+    return nil, nil, err
+  } else if err != nil {
+    return nil, nil, err
+  }
+
+  if g.IsOver() {
+    g.CardActionStack = nil
+    for _, el := range []*UpdateInfo{info, oppInfo} {
+      el.Phase = PHASE_GAME_OVER
+      el.SelectableCards = make([]uint, 0)
+      el.Options = nil
+    }
+  }
+
+  g.recordAction(user, action, info, oppInfo)
+  return info, oppInfo, nil
+}
+
+func (g *Game) processAction(user uint8, action *Action) (*UpdateInfo, *UpdateInfo, error) {
+  // conceding is the only action allowed on the opponent's turn
+  if ActionType(action.ActionType) == ActionTypeConcede {
+    if !g.Rules.AllowConcession {
+      return nil, nil, newGameError(ErrorCodeCantConcede, "conceding isn't allowed in this game")
+    }
+    g.win(1-user, EndReasonConcession)
+    return g.emptyInfo(), g.emptyInfo(), nil
+  }
+
+  if !g.IsPlayersTurn(user) {
+    return nil, nil, newGameError(ErrorCodeNotYourTurn, "it is player %d's turn", g.ActivePlayer)
+  }
+
+  if (ActionType(action.ActionType) == ActionTypeEndTurn) {
+    // whatever is pending can always be finished, since cards
+    // are only played if their effect can resolve, and a 
+    // selection never asks for more cards than there are
+    if g.CardActionStack != nil {
+      return nil, nil, newGameError(ErrorCodeEffectPending, "the current effect has to resolve before ending the turn")
+    }
+    return g.endTurn(user)
+  } else if (ActionType(action.ActionType) == ActionTypeSelectCard) {
     fmt.Printf("Action: Play Card\n")
 
     if (len(action.SelectedCards) != 1) {
-      return &UpdateInfo{}, &UpdateInfo{}, fmt.Errorf("play card was triggered with multiple cards")
+      return &UpdateInfo{}, &UpdateInfo{}, newGameError(ErrorCodeInvalidAction, "play card was triggered with %d cards", len(action.SelectedCards))
+    }
+
+    // playing a card while a choice is pending would throw the
+    // pending effect away, so the choice has to be made first
+    if g.CardActionStack != nil {
+      return nil, nil, newGameError(ErrorCodeEffectPending, "the current effect has to resolve before playing another card")
     }
 
     if (action.From == HAND_PILE) {
@@ -159,13 +276,21 @@ func (g *Game) ProcessAction(user uint8, action *Action) (*UpdateInfo, *UpdateIn
       card := playerHand.find(action.SelectedCards[0])
       if card == nil {
         fmt.Printf("Can't find card\n")
-        return &UpdateInfo{}, &UpdateInfo{}, fmt.Errorf("Can't find card\n")
+        return &UpdateInfo{}, &UpdateInfo{}, newGameError(ErrorCodeUnknownCard, "can't find card %d in hand", action.SelectedCards[0])
+      }
+
+      staticCardData, err := g.CardHandler.Lookup(card.Identity())
+      if err != nil {
+        return &UpdateInfo{}, &UpdateInfo{}, err
       }
 
-      staticCardData := g.CardHandler.cardLookup["set1"][card.ID]
+      // checked before anything moves, so a card can't be
+      // started that would leave the player stuck partway
+      if !g.isPlayable(user, *card) {
+        return nil, nil, newGameError(ErrorCodeCantPlay, "card %d can't be played right now", action.SelectedCards[0])
+      }
 
       if staticCardData.Effect != nil { 
-        g.CardActionStack = nil
         info, _, err := g.processCardAction(user, staticCardData.Effect, action, nil)
         if err != nil {
           return nil, nil, err
@@ -189,42 +314,50 @@ func (g *Game) ProcessAction(user uint8, action *Action) (*UpdateInfo, *UpdateIn
         return info, g.toOppInfo(info), nil
       }
     }
-  } else if ActionType(action.ActionType) == ActionTypeFinishSelection {
-    if g.CardActionStack != nil {
-      info, _, err := g.processCardAction(user, nil, action, nil)
-      if err != nil {
-        return nil, nil, err
-      }
-      return info, g.toOppInfo(info), nil
+  } else if ActionType(action.ActionType) == ActionTypeChooseOption {
+    pending := g.CardActionStack.pending()
+    if pending == nil || pending.lastEffect.Kind != EffectOr || pending.lastArgument != awaitingOption {
+      return nil, nil, newGameError(ErrorCodeNothingPending, "not waiting on an option to be chosen")
+    }
+    if !g.isEffectOption(user, pending.lastEffect, action.Option) {
+      return nil, nil, newGameError(ErrorCodeInvalidOption, "option %d can't be chosen", action.Option)
     }
 
-    playerHand, ok := g.Players[user].PlayerPiles[HAND_PILE]
-    if !ok { return nil, nil, errors.New("Could not find hand") }
-
-    movements := make([]CardMovement, 0, len(action.SelectedCards))
-    for _, el := range action.SelectedCards {
-      movements = append(movements, CardMovement{
-        From: HAND_PILE,
-        To: DISCARD_PILE,
-        GameID: el,
-        CardID: playerHand.find(el).ID, 
-      })
+    info, _, err := g.processCardAction(user, nil, action, nil)
+    if err != nil {
+      return nil, nil, err
+    }
+    return info, g.toOppInfo(info), nil
+  } else if ActionType(action.ActionType) == ActionTypeFinishSelection {
+    pending := g.CardActionStack.pending()
+    if pending == nil || pending.lastEffect.Kind != EffectTarget {
+      return nil, nil, newGameError(ErrorCodeNothingPending, "not waiting on cards to be selected")
     }
 
-    selectableCards := make([]uint, 0, len(playerHand.Cards))
-    for _, thisCard := range playerHand.Cards {
-      selectableCards = append(selectableCards, thisCard.GameID)
+    // checked before resuming, so an invalid selection
+    // leaves the pending selection active
+    err := g.validateSelection(user, &pending.lastEffect.Filter, action.SelectedCards)
+    if err != nil {
+      return nil, nil, err
     }
 
-    info := &UpdateInfo{
-      Movements: movements,
-      Phase: PHASE_MY_TURN,
-      Pile: HAND_PILE,
-      OpenViewCards: make([]uint, 0),
-      SelectableCards: selectableCards,
+    info, _, err := g.processCardAction(user, nil, action, nil)
+    if err != nil {
+      return nil, nil, err
     }
     return info, g.toOppInfo(info), nil
   }
 
-  return &UpdateInfo{}, &UpdateInfo{}, fmt.Errorf("Not sure how to handle action")
+  return &UpdateInfo{}, &UpdateInfo{}, newGameError(ErrorCodeInvalidAction, "not sure how to handle action of type %d from %s", action.ActionType, action.From)
+}
+
+// Returns an update which doesn't change anything
+func (g *Game) emptyInfo() *UpdateInfo {
+  return &UpdateInfo{
+    Movements: make([]CardMovement, 0),
+    Phase: PHASE_OPPONENTS_TURN,
+    Pile: HAND_PILE,
+    OpenViewCards: make([]uint, 0),
+    SelectableCards: make([]uint, 0),
+  }
 }
@@ -0,0 +1,70 @@
+package gamemanager
+
+import (
+  "errors"
+  "fmt"
+)
+
+// These variables should correspond exactly with 
+// enums in client code
+
+type ErrorCode string
+
+const (
+  ErrorCodeInvalidSelection = ErrorCode("INVALID_SELECTION")
+  ErrorCodeInvalidOption    = ErrorCode("INVALID_OPTION")
+  ErrorCodeNothingPending   = ErrorCode("NOTHING_PENDING")
+  ErrorCodeNotYourTurn      = ErrorCode("NOT_YOUR_TURN")
+  ErrorCodeEffectPending    = ErrorCode("EFFECT_PENDING")
+  ErrorCodeGameOver         = ErrorCode("GAME_OVER")
+  ErrorCodeCantConcede      = ErrorCode("CANT_CONCEDE")
+  ErrorCodeCantPlay         = ErrorCode("CANT_PLAY")
+  ErrorCodeUnknownCard      = ErrorCode("UNKNOWN_CARD")
+  ErrorCodeIllegalDeck      = ErrorCode("ILLEGAL_DECK")
+  ErrorCodeInvalidAction    = ErrorCode("INVALID_ACTION")
+  ErrorCodeMalformedMessage = ErrorCode("MALFORMED_MESSAGE")
+  ErrorCodeInternal         = ErrorCode("INTERNAL")
+  ErrorCodeUnknownToken     = ErrorCode("UNKNOWN_TOKEN")
+  ErrorCodeUnknownRoom      = ErrorCode("UNKNOWN_ROOM")
+  ErrorCodeOpponentLeft     = ErrorCode("OPPONENT_LEFT")
+)
+
+// An error caused by an action a player sent, which leaves
+// the game as it was before the action
+type GameError struct {
+  Code    ErrorCode `json:"code"`
+  Message string    `json:"message"`
+}
+
+func newGameError(code ErrorCode, format string, args ...any) *GameError {
+  return &GameError{
+    Code: code,
+    Message: fmt.Sprintf(format, args...),
+  }
+}
+
+func (e *GameError) Error() string {
+  return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Returns err as a GameError, treating errors that 
+// aren't one as internal errors. What went wrong inside
+// the server is left for the server's log, and not sent
+// to the client
+func ToGameError(err error) *GameError {
+  var gameError *GameError
+  if errors.As(err, &gameError) {
+    return gameError
+  }
+  return &GameError{
+    Code: ErrorCodeInternal,
+    Message: "something went wrong on the server",
+  }
+}
+
+// Returns whether err is a GameError, meaning the action
+// was rejected without changing the game
+func IsGameError(err error) bool {
+  var gameError *GameError
+  return errors.As(err, &gameError)
+}
@@ -22,12 +22,32 @@ func toOpp(pile Pile) Pile {
   }
 }
 
-// Returns 0 if card is going to hidden pile
-func (g *Game) zeroIfHidden(id uint, to Pile) uint {
+// Takes one of the opponent's piles and converts it to the
+// owner's equivalent of that pile
+func fromOpp(pile Pile) Pile {
+  if pile == OPP_HAND_PILE {
+    return HAND_PILE
+  } else if pile == OPP_RESERVE_PILE {
+    return RESERVE_PILE
+  } else if pile == OPP_SPECIALS_PILE {
+    return SPECIAL_PILE
+  } else if pile == OPP_BATTLEFIELD_PILE {
+    return BATTLEFIELD_PILE
+  } else if pile == OPP_DISCARD_PILE {
+    return DISCARD_PILE
+  } else if pile == OPP_DECK_PILE {
+    return DECK_PILE
+  } else {
+    return pile
+  }
+}
+
+// Returns the zero identity if card is going to hidden pile
+func (g *Game) zeroIfHidden(card CardIdentity, to Pile) CardIdentity {
   if g.PerPlayerPiles[to].publicKnowledge {
-    return id
+    return card
   } else {
-    return 0
+    return CardIdentity{}
   }
 }
 
@@ -38,11 +58,13 @@ func (g *Game) mergeMoves(thisPlayerMoves *[]CardMovement, oppPlayerMoves *[]Car
     ret = append(ret, movement)
   }
   for _, movement := range *oppPlayerMoves{
+    card := g.zeroIfHidden(movement.Identity(), movement.To)
     ret = append(ret, CardMovement{
       From: toOpp(movement.From),
       To: toOpp(movement.To),
       GameID: movement.GameID,
-      CardID: g.zeroIfHidden(movement.CardID, movement.To),
+      CardID: card.ID,
+      Set: card.Set,
     })
   }
   return &ret
@@ -52,11 +74,64 @@ func (g *Game) mergeMoves(thisPlayerMoves *[]CardMovement, oppPlayerMoves *[]Car
 // send to the opponent
 func (g *Game) toOppInfo(info *UpdateInfo) *UpdateInfo {
   empty := (make([]CardMovement, 0))
+
+  var shuffledPiles []Pile
+  for _, pile := range info.ShuffledPiles {
+    shuffledPiles = append(shuffledPiles, toOpp(pile))
+  }
+
   return &UpdateInfo{
     Movements: *g.mergeMoves(&empty, &info.Movements),
     Phase: PHASE_OPPONENTS_TURN,
     Pile: HAND_PILE,
     OpenViewCards: make([]uint, 0),
     SelectableCards: make([]uint, 0),
+    ShuffledPiles: shuffledPiles,
+  }
+}
+
+// Takes the UpdateInfo sent to the first player, and returns the
+// equivalent to send to spectators, who can't see either hand
+func (g *Game) ToSpectatorInfo(info *UpdateInfo) *UpdateInfo {
+  movements := make([]CardMovement, 0, len(info.Movements))
+  for _, movement := range info.Movements {
+    card := g.zeroIfHidden(movement.Identity(), fromOpp(movement.To))
+    movement.CardID, movement.Set = card.ID, card.Set
+    movements = append(movements, movement)
+  }
+
+  return &UpdateInfo{
+    Movements: movements,
+    Phase: PHASE_SPECTATING,
+    Pile: HAND_PILE,
+    OpenViewCards: make([]uint, 0),
+    SelectableCards: make([]uint, 0),
+    ShuffledPiles: info.ShuffledPiles,
+  }
+}
+
+// Takes the UpdateInfo sent to the first player, and returns the
+// equivalent with every card shown, for spectators kept far enough
+// behind the game that they can't pass on what they see
+func (g *Game) ToOmniscientInfo(info *UpdateInfo) *UpdateInfo {
+  omniscientInfo := g.ToSpectatorInfo(info)
+  for i, movement := range omniscientInfo.Movements {
+    card := g.findCardIdentity(movement.GameID, movement.Identity())
+    omniscientInfo.Movements[i].CardID = card.ID
+    omniscientInfo.Movements[i].Set = card.Set
+  }
+  return omniscientInfo
+}
+
+// Returns the identity of the card with this game ID, or
+// the fallback if it isn't in any pile
+func (g *Game) findCardIdentity(gameID uint, fallback CardIdentity) CardIdentity {
+  for _, player := range g.Players {
+    for _, group := range player.PlayerPiles {
+      if card := group.find(gameID); card != nil {
+        return card.Identity()
+      }
+    }
   }
+  return fallback
 }
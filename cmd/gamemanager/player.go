@@ -57,6 +57,7 @@ func (p *Player) moveCardTo(gameID uint, to *CardGroup) CardMovement {
     From: from.Pile,
     To: to.Pile,
     CardID: card.ID,
+    Set: card.Set,
   }
 }
 
@@ -70,6 +71,7 @@ func (p *Player) moveFromTopTo(from *CardGroup, to *CardGroup, numberOfCards uin
       newMovements = append(newMovements, CardMovement{
         GameID: uint(from.Cards[i].GameID),
         CardID: uint(from.Cards[i].ID),
+        Set: from.Cards[i].Set,
         From: from.Pile,
         To: to.Pile,
       })
@@ -88,6 +90,7 @@ func (p *Player) moveFromTopTo(from *CardGroup, to *CardGroup, numberOfCards uin
     newMovements = append(newMovements, CardMovement{
       GameID: uint(from.Cards[len(from.Cards)-i-1].GameID),
       CardID: uint(from.Cards[len(from.Cards)-i-1].ID),
+      Set: from.Cards[len(from.Cards)-i-1].Set,
       From: from.Pile,
       To: to.Pile,
     })
@@ -5,30 +5,182 @@ import (
 	"fmt"
 )
 
-func (g *Game) getApplicableCards(user uint8, filter *CardFilter) *[]uint {
+// Stored as the lastArgument of an "OR" on the card action
+// stack while the player hasn't chosen a branch yet
+const awaitingOption = -1
+
+// Returns the game IDs of the user's cards matching the filter. 
+// "AND" keeps cards matching every argument and "OR" keeps cards 
+// matching any argument, so a filter can span multiple piles. The
+// Count of the outermost filter restricts the combined selection.
+func (g *Game) getApplicableCards(user uint8, filter *CardFilter) (*[]uint, error) {
   switch filter.Kind {
-  case "AND": 
-    fmt.Println("UNHANDLED FILTER KIND:", filter.Kind)
-    return nil
-  case "OR": 
-    fmt.Println("UNHANDLED FILTER KIND:", filter.Kind)
-    return nil
-  case "JUST": 
+  case FilterAnd: 
+    if len(filter.Args) == 0 {
+      return nil, errors.New("AND filter needs at least one argument")
+    }
+
+    var cards []uint
+    for index, arg := range filter.Args {
+      argCards, err := g.getApplicableCards(user, arg)
+      if err != nil { return nil, err }
+
+      if index == 0 {
+        cards = *argCards
+      } else {
+        cards = intersectCards(cards, *argCards)
+      }
+    }
+    return &cards, nil
+  case FilterOr: 
+    if len(filter.Args) == 0 {
+      return nil, errors.New("OR filter needs at least one argument")
+    }
+
+    cards := make([]uint, 0)
+    for _, arg := range filter.Args {
+      argCards, err := g.getApplicableCards(user, arg)
+      if err != nil { return nil, err }
+
+      cards = unionCards(cards, *argCards)
+    }
+    return &cards, nil
+  case FilterJust: 
     cards := make([]uint, 0)
 
     playerPile, ok := g.Players[user].PlayerPiles[Pile(filter.Pile)]
-    if !ok { fmt.Println("Could not find pile", filter.Pile); return nil }
+    if !ok { return nil, fmt.Errorf("could not find pile %s", filter.Pile) }
 
-    for _, card := range playerPile.Cards {
-      if filter.Type == "" || g.CardHandler.cardLookup["set1"][card.ID].CardType == filter.Type {
+    candidates := playerPile.Cards
+    if filter.Top > 0 {
+      candidates = playerPile.top(filter.Top)
+    }
+
+    for _, card := range candidates {
+      if filter.Type == "" {
+        cards = append(cards, card.GameID)
+        continue
+      }
+
+      staticCardData, err := g.CardHandler.Lookup(card.Identity())
+      if err != nil { return nil, err }
+      if staticCardData.CardType == filter.Type {
         cards = append(cards, card.GameID)
       }
     }
-    return &cards
+    return &cards, nil
   default: 
-    fmt.Println("UNKNOWN FILTER KIND:", filter.Kind)
-    return nil
+    return nil, fmt.Errorf("unknown filter kind: %s", filter.Kind)
+  }
+}
+
+// Returns an error if the selected cards don't fit the filter, 
+// or their number doesn't fit the filter's count. An AtMost of 0
+// means there's no upper bound.
+func (g *Game) validateSelection(user uint8, filter *CardFilter, selectedCards []uint) error {
+  applicableCards, err := g.getApplicableCards(user, filter)
+  if err != nil {
+    return err
+  }
+
+  count := selectionCount(filter.Count, len(*applicableCards))
+  if len(selectedCards) < count.AtLeast {
+    return newGameError(ErrorCodeInvalidSelection, "selected %d cards, but at least %d are needed", len(selectedCards), count.AtLeast)
+  }
+  if count.AtMost > 0 && len(selectedCards) > count.AtMost {
+    return newGameError(ErrorCodeInvalidSelection, "selected %d cards, but at most %d are allowed", len(selectedCards), count.AtMost)
+  }
+
+  applicable := make(map[uint]bool, len(*applicableCards))
+  for _, gameID := range *applicableCards {
+    applicable[gameID] = true
+  }
+
+  seen := make(map[uint]bool, len(selectedCards))
+  for _, gameID := range selectedCards {
+    if seen[gameID] {
+      return newGameError(ErrorCodeInvalidSelection, "card %d was selected more than once", gameID)
+    }
+    seen[gameID] = true
+
+    if !applicable[gameID] {
+      return newGameError(ErrorCodeInvalidSelection, "card %d can't be selected", gameID)
+    }
+  }
+  return nil
+}
+
+// Returns the count a selection has to meet when only so many
+// cards can be chosen. A card is only played if its selections
+// can be met, but an earlier part of its effect can still take
+// cards away, and the player would otherwise be stuck
+func selectionCount(count CountRestriction, applicable int) CountRestriction {
+  if count.AtLeast > applicable {
+    count.AtLeast = applicable
+  }
+  return count
+}
+
+// Returns the cards a filter lets the user look at which they
+// couldn't otherwise see, which are the top cards of hidden piles
+func (g *Game) getRevealedCards(user uint8, filter *CardFilter) []CardReveal {
+  revealed := make([]CardReveal, 0)
+  switch filter.Kind {
+  case FilterAnd, FilterOr:
+    seen := make(map[uint]bool)
+    for _, arg := range filter.Args {
+      for _, reveal := range g.getRevealedCards(user, arg) {
+        if !seen[reveal.GameID] {
+          seen[reveal.GameID] = true
+          revealed = append(revealed, reveal)
+        }
+      }
+    }
+  case FilterJust:
+    playerPile, ok := g.Players[user].PlayerPiles[Pile(filter.Pile)]
+    if !ok || filter.Top <= 0 || playerPile.PublicKnowledge { return revealed }
+
+    for _, card := range playerPile.top(filter.Top) {
+      revealed = append(revealed, CardReveal{
+        GameID: card.GameID,
+        CardID: card.ID,
+        Set: card.Set,
+      })
+    }
   }
+  return revealed
+}
+
+// Returns the game IDs in both a and b, in the order of a
+func intersectCards(a []uint, b []uint) []uint {
+  inB := make(map[uint]bool, len(b))
+  for _, gameID := range b {
+    inB[gameID] = true
+  }
+
+  ret := make([]uint, 0, len(a))
+  for _, gameID := range a {
+    if inB[gameID] {
+      ret = append(ret, gameID)
+    }
+  }
+  return ret
+}
+
+// Returns the game IDs in either a or b without duplicates, 
+// with those from a first
+func unionCards(a []uint, b []uint) []uint {
+  seen := make(map[uint]bool, len(a)+len(b))
+  ret := make([]uint, 0, len(a)+len(b))
+  for _, group := range [][]uint{a, b} {
+    for _, gameID := range group {
+      if !seen[gameID] {
+        seen[gameID] = true
+        ret = append(ret, gameID)
+      }
+    }
+  }
+  return ret
 }
 
 func (g *Game) getPlayableCards(user uint8) *[]uint {
@@ -36,23 +188,96 @@ func (g *Game) getPlayableCards(user uint8) *[]uint {
   playerHand, ok := g.Players[user].PlayerPiles[HAND_PILE]
   if !ok { fmt.Println("Could not find hand"); return nil }
   for _, card := range playerHand.Cards {
-    cond := g.CardHandler.cardLookup["set1"][card.ID].PreCondition
-    condEval := true
-    if cond != nil {
-      var err error
-      condEval, err = g.evaluateBoolExpression(user, cond)
-      if err != nil {
-        fmt.Printf("Error evaluating precondition on card with CardID: %d\n", card.ID)
-      }
-    }
-
-    if condEval {
+    if g.isPlayable(user, card) {
       playable = append(playable, card.GameID)
     }
   }
   return &playable
 }
 
+// Returns whether the card's precondition holds and its
+// effect can be resolved
+func (g *Game) isPlayable(user uint8, card Card) bool {
+  staticCardData, err := g.CardHandler.Lookup(card.Identity())
+  if err != nil {
+    fmt.Printf("Error finding card %s: %s\n", card.Identity(), err)
+    return false
+  }
+
+  cond := staticCardData.PreCondition
+  condEval := true
+  if cond != nil {
+    var err error
+    condEval, err = g.evaluateBoolExpression(user, cond)
+    if err != nil {
+      fmt.Printf("Error evaluating precondition on card %s\n", card.Identity())
+    }
+  }
+
+  // a card whose effect can't resolve would leave the
+  // player stuck partway through it
+  if condEval && staticCardData.Effect != nil {
+    condEval = g.canResolve(user, staticCardData.Effect)
+  }
+  return condEval
+}
+
+// Returns whether the effect could be resolved in the current
+// game state. Each argument of a "THEN" is checked against the
+// state before any of them resolve, so this is a best guess.
+func (g *Game) canResolve(user uint8, effect *CardEffect) bool {
+  if effect.PreCondition != nil {
+    condEval, err := g.evaluateBoolExpression(user, effect.PreCondition)
+    if err != nil {
+      fmt.Printf("Error evaluating precondition on effect of kind %s\n", effect.Kind)
+      return false
+    }
+    if !condEval { return false }
+  }
+
+  switch effect.Kind {
+  case EffectThen:
+    for _, el := range effect.Args {
+      if !g.canResolve(user, el) { return false }
+    }
+    return true
+  case EffectOr:
+    return len(g.getEffectOptions(user, effect)) > 0
+  case EffectMove, EffectShuffle:
+    if effect.CardTarget == nil { return true }
+    return g.canResolve(user, effect.CardTarget)
+  case EffectTarget:
+    if effect.TargetType != TargetSelect && effect.TargetType != TargetAll { return true }
+    cards, err := g.getApplicableCards(user, &effect.Filter)
+    return err == nil && len(*cards) >= effect.Filter.Count.AtLeast
+  default:
+    return true
+  }
+}
+
+// Returns the branches of an "OR" effect which can be resolved
+func (g *Game) getEffectOptions(user uint8, effect *CardEffect) []EffectOption {
+  options := make([]EffectOption, 0, len(effect.Args))
+  for index, el := range effect.Args {
+    if g.canResolve(user, el) {
+      options = append(options, EffectOption{
+        Index: index,
+        Label: el.Label,
+      })
+    }
+  }
+  return options
+}
+
+// Returns whether the given branch of an "OR" effect can 
+// be chosen
+func (g *Game) isEffectOption(user uint8, effect *CardEffect, choice int) bool {
+  for _, option := range g.getEffectOptions(user, effect) {
+    if option.Index == choice { return true }
+  }
+  return false
+}
+
 func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Action, targetToPopulate *[]uint) (*UpdateInfo, bool, error) {
   fromStack := g.CardActionStack != nil
   effect := cardEffect
@@ -67,12 +292,19 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
 
   fmt.Println("KIND:", effect.Kind)
   switch effect.Kind {
-  case "THEN":
+  case EffectThen:
     var info UpdateInfo
     info.Movements = make([]CardMovement, 0)
     for i := startIndex; i < len(effect.Args); i++ {
       el := effect.Args[i]
-      localInfo, controlReturned, err := g.processCardAction(user, el, action, nil)
+
+      // only the argument being resumed takes the action that
+      // resumed it, the rest take the one that played the card
+      argAction := incitingAction
+      if fromStack && i == startIndex {
+        argAction = action
+      }
+      localInfo, controlReturned, err := g.processCardAction(user, el, argAction, nil)
       if err != nil {
         return nil, false, err
       }
@@ -82,9 +314,17 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
       info.SelectionRestrictions = localInfo.SelectionRestrictions
       info.OpenViewCards = localInfo.OpenViewCards
       info.Phase = localInfo.Phase
+      info.Options = localInfo.Options
+      info.RevealedCards = localInfo.RevealedCards
       for _, movement := range localInfo.Movements {
         info.Movements = append(info.Movements, movement)
       }
+      info.ShuffledPiles = append(info.ShuffledPiles, localInfo.ShuffledPiles...)
+
+      // nothing after an effect which ended the game resolves
+      if g.IsOver() {
+        return &info, false, nil
+      }
 
       if controlReturned {
         g.CardActionStack = &CardActionStack{
@@ -97,9 +337,73 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
       }
     }
     return &info, false, nil
-  case "OR":
-    return nil, false, fmt.Errorf("Unhandled Effect Kind: %s\n", effect.Kind)
-  case "MOVE":
+  case EffectOr:
+    choice := startIndex
+    branchAction := action
+    if !fromStack {
+      options := g.getEffectOptions(user, effect)
+      if len(options) == 0 {
+        // cards are only played if a branch can resolve, so an
+        // earlier part of the effect took away what every branch
+        // needed, and the OR does nothing
+        return &UpdateInfo{
+          Movements: make([]CardMovement, 0),
+          Phase: PHASE_MY_TURN,
+          Pile: HAND_PILE,
+          OpenViewCards: make([]uint, 0),
+          SelectableCards: *g.getPlayableCards(user),
+        }, false, nil
+      }
+
+      if len(options) > 1 {
+        g.CardActionStack = &CardActionStack{
+          lastArgument: awaitingOption,
+          lastEffect: effect,
+          inner: g.CardActionStack,
+          incitingAction: incitingAction,
+        }
+
+        return &UpdateInfo{
+          Movements: make([]CardMovement, 0),
+          Phase: PHASE_SELECTING_OPTION,
+          Pile: HAND_PILE,
+          OpenViewCards: make([]uint, 0),
+          SelectableCards: make([]uint, 0),
+          Options: options,
+        }, true, nil
+      }
+
+      // nothing to choose between, so resolve the only option
+      choice = options[0].Index
+    } else if startIndex == awaitingOption {
+      choice = action.Option
+      branchAction = incitingAction
+    }
+
+    if choice < 0 || choice >= len(effect.Args) {
+      return nil, false, fmt.Errorf("invalid option %d for OR effect\n", choice)
+    }
+
+    info, controlReturned, err := g.processCardAction(user, effect.Args[choice], branchAction, targetToPopulate)
+    if err != nil {
+      return nil, false, err
+    }
+    if controlReturned {
+      g.CardActionStack = &CardActionStack{
+        lastArgument: choice,
+        lastEffect: effect,
+        inner: g.CardActionStack,
+        incitingAction: incitingAction,
+      }
+      return info, true, nil
+    }
+    return info, false, nil
+  case EffectMove:
+    // checked before the target takes what is left of the deck
+    if count := effect.drawCount(); count > 0 {
+      g.checkDeckOut(user, count)
+    }
+
     var selectedCards []uint
     info, controlReturned, err := g.processCardAction(user, effect.CardTarget, action, &selectedCards)
     if err != nil {
@@ -114,8 +418,6 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
       return info, true, nil
     }
 
-    fmt.Println("TODO: Validate selected cards fit filter")
-
     movements := make([]CardMovement, 0)
     for _, cardGameID := range selectedCards {
       group, ok := g.Players[user].PlayerPiles[Pile(effect.To)]
@@ -138,9 +440,76 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
       SelectableCards: *g.getPlayableCards(user),
     }
     return returnInfo, false, nil
-  case "SHUFFLE":
-    return nil, false, fmt.Errorf("Unhandled Effect Kind: %s\n", effect.Kind)
-  case "TARGET":
+  case EffectShuffle:
+    groups := make([]*CardGroup, 0, 1)
+
+    if effect.Pile != "" {
+      group, ok := g.Players[user].PlayerPiles[Pile(effect.Pile)]
+      if !ok { return nil, false, fmt.Errorf("could not find pile %s\n", effect.Pile) }
+      groups = append(groups, group)
+    } else if effect.CardTarget != nil {
+      var selectedCards []uint
+      info, controlReturned, err := g.processCardAction(user, effect.CardTarget, action, &selectedCards)
+      if err != nil {
+        return &UpdateInfo{}, false, err
+      }
+      if controlReturned {
+        g.CardActionStack = &CardActionStack{
+          lastEffect: effect,
+          inner: g.CardActionStack,
+          incitingAction: incitingAction,
+        }
+        return info, true, nil
+      }
+
+      // shuffle each pile containing a target exactly once
+      for _, cardGameID := range selectedCards {
+        group, ok := g.Players[user].FindID[cardGameID]
+        if !ok { return nil, false, fmt.Errorf("could not find card with game id %d\n", cardGameID) }
+
+        alreadyIncluded := false
+        for _, el := range groups {
+          if el == group { alreadyIncluded = true; break }
+        }
+        if !alreadyIncluded {
+          groups = append(groups, group)
+        }
+      }
+    } else {
+      return nil, false, errors.New("shuffle effect needs either a pile or a target")
+    }
+
+    shuffledPiles := make([]Pile, 0, len(groups))
+    for _, group := range groups {
+      g.shuffle(user, group)
+      shuffledPiles = append(shuffledPiles, group.Pile)
+    }
+
+    return &UpdateInfo{
+      Movements: make([]CardMovement, 0),
+      Phase: PHASE_MY_TURN,
+      Pile: HAND_PILE,
+      OpenViewCards: make([]uint, 0),
+      SelectableCards: *g.getPlayableCards(user),
+      ShuffledPiles: shuffledPiles,
+    }, false, nil
+  case EffectWin, EffectLose, EffectDrawGame:
+    if effect.Kind == EffectWin {
+      g.win(user, EndReasonCardEffect)
+    } else if effect.Kind == EffectLose {
+      g.win(1-user, EndReasonCardEffect)
+    } else {
+      g.draw(EndReasonCardEffect)
+    }
+
+    return &UpdateInfo{
+      Movements: make([]CardMovement, 0),
+      Phase: PHASE_GAME_OVER,
+      Pile: HAND_PILE,
+      OpenViewCards: make([]uint, 0),
+      SelectableCards: make([]uint, 0),
+    }, false, nil
+  case EffectTarget:
     if fromStack { 
       if targetToPopulate == nil {
         return &UpdateInfo{}, false, errors.New("tried to populate target, but pointer was nil")
@@ -150,7 +519,12 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
       return &UpdateInfo{}, false, nil 
     }
 
-    if effect.TargetType == "SELECT" {
+    if effect.TargetType == TargetSelect {
+      applicableCards, err := g.getApplicableCards(user, &effect.Filter)
+      if err != nil {
+        return nil, false, err
+      }
+
       g.CardActionStack = &CardActionStack{
         lastEffect: effect,
         inner: g.CardActionStack,
@@ -158,15 +532,43 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
 
       fmt.Println("Target", effect.Filter.Count)
 
+      // the revealed cards stop being open once the next 
+      // update is sent, since that has no open view cards
+      revealedCards := g.getRevealedCards(user, &effect.Filter)
+      openViewCards := make([]uint, 0, len(revealedCards))
+      for _, reveal := range revealedCards {
+        openViewCards = append(openViewCards, reveal.GameID)
+      }
+
       return &UpdateInfo{
         Movements: make([]CardMovement, 0),
         Phase: PHASE_SELECTING_CARDS,
         Pile: HAND_PILE,
-        OpenViewCards: make([]uint, 0),
-        SelectableCards: *g.getApplicableCards(user, &effect.Filter),
-        SelectionRestrictions: effect.Filter.Count,
+        OpenViewCards: openViewCards,
+        SelectableCards: *applicableCards,
+        SelectionRestrictions: selectionCount(effect.Filter.Count, len(*applicableCards)),
+        RevealedCards: revealedCards,
       }, true, nil 
-    } else if effect.TargetType == "THIS" {
+    } else if effect.TargetType == TargetAll {
+      if targetToPopulate == nil {
+        return &UpdateInfo{}, false, errors.New("tried to populate target, but pointer was nil")
+      }
+
+      applicableCards, err := g.getApplicableCards(user, &effect.Filter)
+      if err != nil {
+        return nil, false, err
+      }
+
+      *targetToPopulate = *applicableCards
+
+      return &UpdateInfo{
+        Movements: make([]CardMovement, 0),
+        Phase: PHASE_MY_TURN,
+        Pile: HAND_PILE,
+        OpenViewCards: make([]uint, 0),
+        SelectableCards: make([]uint, 0),
+      }, false, nil 
+    } else if effect.TargetType == TargetThis {
       if len(incitingAction.SelectedCards) != 1 {
         return &UpdateInfo{}, false, fmt.Errorf("TargetType this, with %d selected cards\n", len(incitingAction.SelectedCards))
       }
@@ -181,10 +583,10 @@ func (g *Game) processCardAction(user uint8, cardEffect *CardEffect, action *Act
         SelectableCards: make([]uint, 0),
       }, false, nil 
     } else {
-      return nil, false, fmt.Errorf("Unhandled Target Type: %s\n", cardEffect.TargetType)
+      return nil, false, fmt.Errorf("Unhandled Target Type: %s\n", effect.TargetType)
     }
   default:
-    return nil, false, fmt.Errorf("Unknown Effect Kind: %s\n", cardEffect.Kind)
+    return nil, false, fmt.Errorf("Unknown Effect Kind: %s\n", effect.Kind)
   }
 
 }
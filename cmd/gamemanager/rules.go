@@ -0,0 +1,71 @@
+package gamemanager
+
+import "encoding/json"
+
+// The name of the file in a card info directory which 
+// configures the rules for games using those cards
+const RulesFileName = "rules.json"
+
+// Game rules that card sets can configure
+type GameRules struct {
+  // A player who has to draw from an empty deck loses
+  DeckOutLoses    bool        `json:"deckOutLoses"`
+  // Players can concede at any time, even on their opponent's turn
+  AllowConcession bool        `json:"allowConcession"`
+  // What decks players are allowed to bring
+  DeckFormat      DeckFormat  `json:"deckFormat"`
+}
+
+func DefaultGameRules() GameRules {
+  return GameRules{
+    DeckOutLoses: true,
+    AllowConcession: true,
+  }
+}
+
+// Parses rules, leaving any rule that isn't given at its default
+func parseRules(content []byte) (GameRules, error) {
+  rules := DefaultGameRules()
+  err := json.Unmarshal(content, &rules)
+  return rules, err
+}
+
+type EndReason string
+
+const (
+  EndReasonDeckOut    = EndReason("DECK_OUT")
+  EndReasonCardEffect = EndReason("CARD_EFFECT")
+  EndReasonConcession = EndReason("CONCESSION")
+  // the server failed partway through an action, so the
+  // game couldn't go on
+  EndReasonError      = EndReason("ERROR")
+)
+
+type GameResult struct {
+  Winner  uint8     `json:"winner"`
+  IsDraw  bool      `json:"isDraw"`
+  Reason  EndReason `json:"reason"`
+}
+
+// Ends the game with the given player as the winner
+func (g *Game) win(winner uint8, reason EndReason) {
+  if g.Result != nil { return }
+  g.Result = &GameResult{
+    Winner: winner,
+    Reason: reason,
+  }
+}
+
+// Ends the game without a winner
+func (g *Game) draw(reason EndReason) {
+  if g.Result != nil { return }
+  g.Result = &GameResult{
+    IsDraw: true,
+    Reason: reason,
+  }
+}
+
+// Returns whether the game has ended
+func (g *Game) IsOver() bool {
+  return g.Result != nil
+}
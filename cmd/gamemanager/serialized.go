@@ -6,20 +6,20 @@ package gamemanager
 type Pile string
 
 const (
-  TEMPORARY             = Pile(0)
+  TEMPORARY             = Pile("TEMPORARY")
   HAND_PILE             = Pile("HAND")
-  RESERVE_PILE          = Pile(2)
-  SPECIAL_PILE          = Pile(3)
-  BATTLEFIELD_PILE      = Pile(4)
+  RESERVE_PILE          = Pile("RESERVE")
+  SPECIAL_PILE          = Pile("SPECIAL")
+  BATTLEFIELD_PILE      = Pile("BATTLEFIELD")
   DISCARD_PILE          = Pile("DISCARD")
   DECK_PILE             = Pile("DECK")
   OPP_HAND_PILE         = Pile("OPP_HAND")
-  OPP_RESERVE_PILE      = Pile(8)
-  OPP_SPECIALS_PILE     = Pile(9)
-  OPP_BATTLEFIELD_PILE  = Pile(10)
+  OPP_RESERVE_PILE      = Pile("OPP_RESERVE")
+  OPP_SPECIALS_PILE     = Pile("OPP_SPECIALS")
+  OPP_BATTLEFIELD_PILE  = Pile("OPP_BATTLEFIELD")
   OPP_DISCARD_PILE      = Pile("OPP_DISCARD")
   OPP_DECK_PILE         = Pile("OPP_DECK")
-  BEING_PLAYED          = Pile(13)
+  BEING_PLAYED          = Pile("BEING_PLAYED")
 )
 
 type MessageType uint 
@@ -31,6 +31,10 @@ const (
   MessageTypeFirstOrSecond        = MessageType(3)
   MessageTypeFirstOrSecondChoice  = MessageType(4)
   MessageTypeGameplay             = MessageType(5)
+  MessageTypeError                = MessageType(6)
+  MessageTypeGameOver             = MessageType(7)
+  MessageTypeSnapshot             = MessageType(8)
+  MessageTypeCommitment           = MessageType(9)
 )
 
 type ActionType uint 
@@ -39,6 +43,8 @@ const (
   ActionTypeEndTurn              = ActionType(0)
   ActionTypeSelectCard           = ActionType(1)
   ActionTypeFinishSelection      = ActionType(2)
+  ActionTypeChooseOption         = ActionType(3)
+  ActionTypeConcede              = ActionType(4)
 )
 
 type Phase uint
@@ -48,4 +54,7 @@ const (
   PHASE_OPPONENTS_TURN            = Phase(1)
   PHASE_SELECTING_CARDS           = Phase(2)
   PHASE_SELECTING_TEMPORARY_CARDS = Phase(3)
+  PHASE_SELECTING_OPTION          = Phase(4)
+  PHASE_GAME_OVER                 = Phase(5)
+  PHASE_SPECTATING                = Phase(6)
 )
@@ -0,0 +1,150 @@
+package gamemanager
+
+import "sort"
+
+// Everything one player can see of the game, so a client
+// can rebuild its state without any earlier updates
+type Snapshot struct {
+  // Cards the viewer can't see have a CardID of 0
+  Piles                 map[Pile][]CardReveal `json:"piles"`
+  Phase                 Phase                 `json:"phase"`
+  SelectableCards       []uint                `json:"selectableCards"`
+  TurnNumber            uint                  `json:"turnNumber"`
+  Result                *GameResult           `json:"result,omitempty"`
+
+  // The selection or choice the player has to make before 
+  // the effect they're resolving can go on
+  SelectionRestrictions CountRestriction      `json:"count,omitempty"`
+  OpenViewCards         []uint                `json:"openViewCards"`
+  RevealedCards         []CardReveal          `json:"revealedCards,omitempty"`
+  Options               []EffectOption        `json:"options,omitempty"`
+}
+
+// Returns the game as the given player sees it, with the
+// opponent's piles under their OPP_ names
+func (g *Game) GetSnapshot(user uint8) *Snapshot {
+  piles := make(map[Pile][]CardReveal, 2*len(g.PerPlayerPiles))
+  for pile, group := range g.Players[user].PlayerPiles {
+    piles[pile] = g.snapshotPile(group, true)
+  }
+  for pile, group := range g.Players[1-user].PlayerPiles {
+    piles[toOpp(pile)] = g.snapshotPile(group, false)
+  }
+
+  snapshot := &Snapshot{
+    Piles: piles,
+    TurnNumber: g.TurnNumber,
+    Result: g.Result,
+    OpenViewCards: make([]uint, 0),
+  }
+  g.fillCurrentPhase(user, snapshot)
+  return snapshot
+}
+
+// Returns the game as someone who isn't playing sees it, with
+// the second player's piles under their OPP_ names
+func (g *Game) GetNeutralSnapshot() *Snapshot {
+  return g.getSpectatorSnapshot(false)
+}
+
+// Returns the game with both hands shown, for spectators kept
+// far enough behind that they can't pass on what they see. Each
+// player's piles are shown as that player sees them, so the
+// order of the decks stays hidden
+func (g *Game) GetOpenHandsSnapshot() *Snapshot {
+  return g.getSpectatorSnapshot(true)
+}
+
+func (g *Game) getSpectatorSnapshot(openHands bool) *Snapshot {
+  piles := make(map[Pile][]CardReveal, 2*len(g.PerPlayerPiles))
+  for index := range g.Players {
+    for pile, group := range g.Players[index].PlayerPiles {
+      if index != 0 {
+        pile = toOpp(pile)
+      }
+      piles[pile] = g.snapshotPile(group, openHands)
+    }
+  }
+
+  return &Snapshot{
+    Piles: piles,
+    Phase: PHASE_SPECTATING,
+    SelectableCards: make([]uint, 0),
+    TurnNumber: g.TurnNumber,
+    Result: g.Result,
+    OpenViewCards: make([]uint, 0),
+  }
+}
+
+// Returns the pile's cards as the viewer sees them. The cards
+// of a pile hidden from the viewer are sorted by GameID, so
+// the order of a pile like the deck isn't given away
+func (g *Game) snapshotPile(group *CardGroup, isOwner bool) []CardReveal {
+  hidden := !g.PerPlayerPiles[group.Pile].publicKnowledge
+  if isOwner {
+    hidden = !g.PerPlayerPiles[group.Pile].ownerKnowledge
+  }
+
+  cards := make([]CardReveal, 0, len(group.Cards))
+  for _, card := range group.Cards {
+    identity := card.Identity()
+    if hidden {
+      identity = CardIdentity{}
+    }
+
+    cards = append(cards, CardReveal{
+      GameID: card.GameID,
+      CardID: identity.ID,
+      Set: identity.Set,
+    })
+  }
+
+  if hidden {
+    sort.Slice(cards, func(i, j int) bool {
+      return cards[i].GameID < cards[j].GameID
+    })
+  }
+  return cards
+}
+
+// Fills in the phase the player is in, along with the cards
+// they can select and anything pending from the card action
+// stack
+func (g *Game) fillCurrentPhase(user uint8, snapshot *Snapshot) {
+  snapshot.SelectableCards = make([]uint, 0)
+
+  if g.IsOver() {
+    snapshot.Phase = PHASE_GAME_OVER
+    return
+  }
+  if !g.IsPlayersTurn(user) {
+    snapshot.Phase = PHASE_OPPONENTS_TURN
+    return
+  }
+
+  pending := g.CardActionStack.pending()
+  if pending == nil {
+    snapshot.Phase = PHASE_MY_TURN
+    snapshot.SelectableCards = *g.getPlayableCards(user)
+    return
+  }
+
+  if pending.lastEffect.Kind == EffectOr {
+    snapshot.Phase = PHASE_SELECTING_OPTION
+    snapshot.Options = g.getEffectOptions(user, pending.lastEffect)
+    return
+  }
+
+  filter := &pending.lastEffect.Filter
+  snapshot.Phase = PHASE_SELECTING_CARDS
+  snapshot.SelectionRestrictions = filter.Count
+  snapshot.RevealedCards = g.getRevealedCards(user, filter)
+  for _, reveal := range snapshot.RevealedCards {
+    snapshot.OpenViewCards = append(snapshot.OpenViewCards, reveal.GameID)
+  }
+
+  applicableCards, err := g.getApplicableCards(user, filter)
+  if err == nil {
+    snapshot.SelectableCards = *applicableCards
+  }
+}
@@ -0,0 +1,76 @@
+package gamemanager
+
+import "errors"
+
+// Returns whether it is currently the given player's turn
+func (g *Game) IsPlayersTurn(user uint8) bool {
+  return g.ActivePlayer == user
+}
+
+// Passes the turn to the opponent, who draws a card to 
+// start their turn
+func (g *Game) endTurn(user uint8) (*UpdateInfo, *UpdateInfo, error) {
+  next := 1 - user
+
+  g.ActivePlayer = next
+  g.TurnNumber++
+
+  empty := make([]CardMovement, 0)
+  drawMoves, err := g.drawCards(next, 1)
+  if err != nil { return nil, nil, err }
+
+  info := &UpdateInfo{
+    Movements: *g.mergeMoves(&empty, drawMoves),
+    Phase: PHASE_OPPONENTS_TURN,
+    Pile: HAND_PILE,
+    OpenViewCards: make([]uint, 0),
+    SelectableCards: make([]uint, 0),
+  }
+  oppInfo := &UpdateInfo{
+    Movements: *g.mergeMoves(drawMoves, &empty),
+    Phase: PHASE_MY_TURN,
+    Pile: HAND_PILE,
+    OpenViewCards: make([]uint, 0),
+    SelectableCards: *g.getPlayableCards(next),
+  }
+  return info, oppInfo, nil
+}
+
+// Draws cards from the top of the player's deck into their hand,
+// decking the player out if their deck has fewer cards left
+func (g *Game) drawCards(player uint8, count uint) (*[]CardMovement, error) {
+  deck, ok := g.Players[player].PlayerPiles[DECK_PILE]
+  if !ok { return nil, errors.New("Could not find deck") }
+  hand, ok := g.Players[player].PlayerPiles[HAND_PILE]
+  if !ok { return nil, errors.New("Could not find hand") }
+
+  g.checkDeckOut(player, count)
+  return g.Players[player].moveFromTopTo(deck, hand, count), nil
+}
+
+// Ends the game if the player has to draw more cards than
+// their deck has left and the rules say that loses
+func (g *Game) checkDeckOut(player uint8, count uint) {
+  deck, ok := g.Players[player].PlayerPiles[DECK_PILE]
+  if ok && uint(len(deck.Cards)) < count && g.Rules.DeckOutLoses {
+    g.win(1-player, EndReasonDeckOut)
+  }
+}
+
+// Returns how many cards a MOVE effect draws, meaning it moves
+// every one of the top cards of the deck into the hand, or 0
+// if it isn't a draw
+func (effect *CardEffect) drawCount() uint {
+  target := effect.CardTarget
+  if effect.Kind != EffectMove || Pile(effect.To) != HAND_PILE || target == nil {
+    return 0
+  }
+  if target.Kind != EffectTarget || target.TargetType != TargetAll {
+    return 0
+  }
+  filter := target.Filter
+  if filter.Kind != FilterJust || Pile(filter.Pile) != DECK_PILE || filter.Top <= 0 || filter.Type != "" {
+    return 0
+  }
+  return uint(filter.Top)
+}
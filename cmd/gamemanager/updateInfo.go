@@ -7,4 +7,22 @@ type UpdateInfo struct {
   OpenViewCards         []uint            `json:"openViewCards"`
   SelectableCards       []uint            `json:"selectableCards"` 
   SelectionRestrictions CountRestriction  `json:"count,omitempty"`
+  ShuffledPiles         []Pile            `json:"shuffledPiles,omitempty"`
+  Options               []EffectOption    `json:"options,omitempty"`
+  RevealedCards         []CardReveal      `json:"revealedCards,omitempty"`
+}
+
+// A card in a hidden pile shown to one player, so 
+// the ones in OpenViewCards can be displayed
+type CardReveal struct {
+  GameID  uint    `json:"gameId"`
+  CardID  uint    `json:"cardId"`
+  // Empty along with CardID when the card is hidden
+  Set     string  `json:"set,omitempty"`
+}
+
+// A branch of an "OR" effect that the player can choose
+type EffectOption struct {
+  Index int     `json:"index"`
+  Label string  `json:"label"`
 }
@@ -0,0 +1,196 @@
+package gamemanager
+
+import (
+  "errors"
+  "fmt"
+  "sort"
+)
+
+// The names the game code knows, which card data can use
+var (
+  effectKinds     = []string{EffectThen, EffectOr, EffectMove, EffectShuffle, EffectTarget, EffectWin, EffectLose, EffectDrawGame}
+  targetTypes     = []string{TargetSelect, TargetAll, TargetThis}
+  filterKinds     = []string{FilterAnd, FilterOr, FilterJust}
+  expressionKinds = []string{ExpressionConstant, ExpressionVariable, ExpressionOperator}
+  variables       = []string{VariableCardsInHand}
+  cardTypes       = []string{CardTypeBasicCharacter, CardTypeSpecialCharacter, CardTypeEvent, CardTypeAction}
+)
+
+// A problem with a card's data, which would otherwise only
+// show up when the card is played
+type ValidationError struct {
+  Set     string
+  // Where the problem is in the set's file,
+  // like [5].effect.args[1].to
+  Path    string
+  Message string
+}
+
+func (e *ValidationError) Error() string {
+  return fmt.Sprintf("%s%s: %s", e.Set, e.Path, e.Message)
+}
+
+// Checks every card for names the game code doesn't know and
+// counts that can't be met. Returns every problem found, each
+// as a ValidationError, or nil if there aren't any. Whatever a
+// card takes from its alias is only checked on the original.
+func (ch *CardHandler) Validate() error {
+  setNames := make([]string, 0, len(ch.cardLookup))
+  for setName := range ch.cardLookup {
+    setNames = append(setNames, setName)
+  }
+  sort.Strings(setNames)
+
+  v := &validator{piles: perPlayerPiles()}
+  for _, setName := range setNames {
+    v.set = setName
+    for index := range ch.cardLookup[setName] {
+      v.validateCard(fmt.Sprintf("[%d]", index), &ch.cardLookup[setName][index])
+    }
+  }
+  return errors.Join(v.errs...)
+}
+
+type validator struct {
+  set   string
+  piles map[Pile]*StaticPileData
+  errs  []error
+}
+
+func (v *validator) report(path string, format string, args ...any) {
+  v.errs = append(v.errs, &ValidationError{
+    Set: v.set,
+    Path: path,
+    Message: fmt.Sprintf(format, args...),
+  })
+}
+
+// Reports the name if it isn't one of the known ones
+func (v *validator) checkName(path string, what string, name string, known []string) {
+  for _, knownName := range known {
+    if name == knownName { return }
+  }
+  v.report(path, "unknown %s %q", what, name)
+}
+
+func (v *validator) checkPile(path string, pile string) {
+  if _, ok := v.piles[Pile(pile)]; !ok {
+    v.report(path, "unknown pile %q", pile)
+  }
+}
+
+func (v *validator) validateCard(path string, card *StaticCardData) {
+  alias := card.Alias
+  if alias == nil {
+    alias = &StaticCardData{}
+  }
+
+  if card.CardType != "" && card.CardType != alias.CardType {
+    v.checkName(path+".cardType", "card type", card.CardType, cardTypes)
+  }
+  if card.PreCondition != nil && card.PreCondition != alias.PreCondition {
+    v.validateExpression(path+".preCondition", card.PreCondition)
+  }
+  if card.Effect != nil && card.Effect != alias.Effect {
+    v.validateEffect(path+".effect", card.Effect)
+  }
+}
+
+func (v *validator) validateEffect(path string, effect *CardEffect) {
+  if effect == nil {
+    v.report(path, "missing effect")
+    return
+  }
+
+  if effect.PreCondition != nil {
+    v.validateExpression(path+".preCondition", effect.PreCondition)
+  }
+
+  switch effect.Kind {
+  case EffectThen, EffectOr:
+    for index, arg := range effect.Args {
+      v.validateEffect(fmt.Sprintf("%s.args[%d]", path, index), arg)
+    }
+  case EffectMove:
+    if effect.CardTarget == nil {
+      v.report(path+".target", "move has no target")
+    } else {
+      v.validateEffect(path+".target", effect.CardTarget)
+    }
+    if effect.To == "" {
+      v.report(path+".to", "move has no pile to move to")
+    } else {
+      v.checkPile(path+".to", effect.To)
+    }
+  case EffectShuffle:
+    if effect.CardTarget == nil && effect.Pile == "" {
+      v.report(path, "shuffle has neither a pile nor a target")
+    }
+    if effect.CardTarget != nil {
+      v.validateEffect(path+".target", effect.CardTarget)
+    }
+    if effect.Pile != "" {
+      v.checkPile(path+".pile", effect.Pile)
+    }
+  case EffectTarget:
+    v.checkName(path+".targetType", "target type", effect.TargetType, targetTypes)
+    if effect.TargetType == TargetSelect || effect.TargetType == TargetAll {
+      v.validateFilter(path+".filter", &effect.Filter)
+    }
+  default:
+    v.checkName(path+".kind", "effect kind", effect.Kind, effectKinds)
+  }
+}
+
+func (v *validator) validateFilter(path string, filter *CardFilter) {
+  if filter == nil {
+    v.report(path, "missing filter")
+    return
+  }
+
+  count := filter.Count
+  if count.AtLeast < 0 || count.AtMost < 0 {
+    v.report(path+".count", "negative count")
+  } else if count.AtMost > 0 && count.AtLeast > count.AtMost {
+    v.report(path+".count", "atLeast %d is more than atMost %d", count.AtLeast, count.AtMost)
+  }
+
+  switch filter.Kind {
+  case FilterAnd, FilterOr:
+    for index, arg := range filter.Args {
+      v.validateFilter(fmt.Sprintf("%s.args[%d]", path, index), arg)
+    }
+  case FilterJust:
+    v.checkPile(path+".pile", filter.Pile)
+    if filter.Type != "" {
+      v.checkName(path+".type", "card type", filter.Type, cardTypes)
+    }
+  default:
+    v.checkName(path+".kind", "filter kind", filter.Kind, filterKinds)
+  }
+}
+
+func (v *validator) validateExpression(path string, expression *Expression) {
+  if expression == nil {
+    v.report(path, "missing expression")
+    return
+  }
+
+  switch expression.Kind {
+  case ExpressionConstant:
+  case ExpressionVariable:
+    v.checkName(path+".variable", "variable", expression.Variable, variables)
+  case ExpressionOperator:
+    argCount, ok := operatorArgCounts[expression.Operator]
+    if !ok {
+      v.report(path+".operator", "unknown operator %q", expression.Operator)
+    } else if len(expression.Args) != argCount {
+      v.report(path+".args", "operator %q takes %d args, got %d", expression.Operator, argCount, len(expression.Args))
+    }
+    for index, arg := range expression.Args {
+      v.validateExpression(fmt.Sprintf("%s.args[%d]", path, index), arg)
+    }
+  default:
+    v.checkName(path+".kind", "expression kind", expression.Kind, expressionKinds)
+  }
+}
@@ -1,21 +1,50 @@
 package main
 
 import (
+  "flag"
   "fmt"
   "log"
   "net/http"
+  "os"
   "github.com/Zarone/CardGameServer/cmd/server"
 )
 
 func main() {
-  myServer := server.MakeServer(&server.ServerSettings{}, "./cardInfo") 
+  strictCards := flag.Bool("strict-cards", false, "refuse to start if any card fails validation (see cmd/cardcheck)")
+  watchCards := flag.Duration("watch-cards", 0, "how often to check ./cardInfo for changes and reload the cards, or 0 to never")
+  flag.Parse()
 
-  // example path: /socket?room=3&spectator=true
-  http.HandleFunc("/socket", myServer.HandleWS)
+  myServer := server.MakeServer(&server.ServerSettings{
+    StrictCards: *strictCards,
+    AdminToken: os.Getenv("ADMIN_TOKEN"),
+  }, "./cardInfo") 
+
+  if *watchCards > 0 {
+    go myServer.WatchCards(*watchCards, nil)
+  }
+
+  // example path: /socket?room=<id>&spectator=true, where the
+  // id comes from creating a room at /api/rooms/create
+  // add &delayed=true to see both hands, held back by the
+  // server's spectator delay
+  http.HandleFunc(server.SocketPath, myServer.HandleWS)
+
+  // example path: /matchmake?sets=set1,set2&rating=1500
+  http.HandleFunc("/matchmake", myServer.HandleMatchmake)
   
   // Add handlers for rooms page and API
   http.HandleFunc("/", myServer.HandleRoomsPage)
   http.HandleFunc("/api/rooms", myServer.HandleRoomsAPI)
+  http.HandleFunc("/api/rooms.json", myServer.HandleRoomsJSON)
+
+  // Admin, with the ADMIN_TOKEN as a bearer token
+  // example: curl -X POST -H "Authorization: Bearer $ADMIN_TOKEN" localhost:3000/admin/reload-cards
+  http.HandleFunc("/admin/reload-cards", myServer.HandleReloadCards)
+
+  // Lobby
+  http.HandleFunc("/api/rooms/create", myServer.HandleCreateRoom)
+  http.HandleFunc("/api/rooms/open", myServer.HandleOpenRooms)
+  http.HandleFunc("/api/rooms/join", myServer.HandleJoinRoom)
 
   fmt.Println("Hello from Server")
   log.Fatal(http.ListenAndServe(":3000", nil))
@@ -10,6 +10,7 @@ type Barrier struct {
 	count         int
 	expectedCount int
 	phase         int
+	broken        bool
 }
 
 func NewBarrier(expectedCount int) *Barrier {
@@ -20,10 +21,16 @@ func NewBarrier(expectedCount int) *Barrier {
 	return b
 }
 
-func (b *Barrier) Wait() {
+// Wait blocks until every thread arrives, returning false
+// if the barrier was broken instead
+func (b *Barrier) Wait() bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.broken {
+		return false
+	}
+
 	phase := b.phase
 	b.count++
 	
@@ -34,8 +41,21 @@ func (b *Barrier) Wait() {
 		b.cond.Broadcast()
 	} else {
 		// Wait until all threads arrive and phase changes
-		for phase == b.phase {
+		for phase == b.phase && !b.broken {
 			b.cond.Wait()
 		}
 	}
+
+	return phase != b.phase
+}
+
+// Break releases every waiting thread, and makes any
+// later Wait return immediately, since a thread which 
+// is never going to arrive would leave the rest stuck
+func (b *Barrier) Break() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.broken = true
+	b.cond.Broadcast()
 }
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zarone/CardGameServer/cmd/gamemanager"
+)
+
+// What the admin endpoint responds with after reloading the cards
+type ReloadResponse struct {
+	Version  uint     `json:"version,omitempty"`
+	// Problems with the new cards, which are still used
+	// outside strict mode
+	Problems []string `json:"problems,omitempty"`
+	// Why the cards couldn't be reloaded, in which case
+	// the old ones are still used
+	Error    string   `json:"error,omitempty"`
+}
+
+// Returns the cards new rooms are made with
+func (s *Server) currentCards() *gamemanager.CardHandler {
+	s.cardsMutex.RLock()
+	defer s.cardsMutex.RUnlock()
+	return s.cardHandler
+}
+
+// Returns the version of the cards new rooms are made with
+func (s *Server) CardVersion() uint {
+	return s.currentCards().Version
+}
+
+// Loads the cards from the card info directory again, as a new
+// version that rooms made from then on use. Rooms that already
+// exist keep the cards they were made with, so games in progress
+// aren't changed under the players. If any file can't be loaded,
+// which may just mean it's still being written, the old cards are
+// kept and an error is returned, even outside strict mode.
+func (s *Server) ReloadCards() (*gamemanager.CardHandler, []error, error) {
+	s.cardsMutex.Lock()
+	defer s.cardsMutex.Unlock()
+
+	cardHandler, problems, err := loadCards(s.cardInfoPath, s.settings.StrictCards, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	cardHandler.Version = s.cardHandler.Version + 1
+	s.cardHandler = cardHandler
+
+	log.Printf("Loaded version %d of the cards from %s\n", cardHandler.Version, s.cardInfoPath)
+	logCardProblems(s.cardInfoPath, problems)
+	return cardHandler, problems, nil
+}
+
+// Reloads the cards whenever a file in the card info directory
+// changes, checking every interval until stop is closed. A reload
+// that fails is tried again every interval until it works
+func (s *Server) WatchCards(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := directorySignature(s.cardInfoPath)
+	// the directory as it was when a reload last failed,
+	// so the same error isn't logged every interval
+	failed := last
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := directorySignature(s.cardInfoPath)
+		if current == last {
+			continue
+		}
+
+		if _, _, err := s.ReloadCards(); err != nil {
+			if current != failed {
+				log.Printf("Error reloading cards: %s", err)
+			}
+			failed = current
+			continue
+		}
+		last = current
+	}
+}
+
+// Returns a string that changes whenever a file in the
+// directory is added, removed or written to
+func directorySignature(path string) string {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return ""
+	}
+
+	var signature strings.Builder
+	for _, e := range entries {
+		info, err := os.Stat(filepath.Join(path, e.Name()))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&signature, "%s:%d:%d;", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return signature.String()
+}
+
+// Reloads the cards for card designers, so they can try out
+// changes without restarting the server. Requires the server's
+// admin token as a bearer token, and is disabled without one
+func (s *Server) HandleReloadCards(w http.ResponseWriter, r *http.Request) {
+	if s.settings.AdminToken == "" {
+		http.Error(w, "Admin endpoints are disabled", http.StatusNotFound)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.settings.AdminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Cards are reloaded with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cardHandler, problems, err := s.ReloadCards()
+	if err != nil {
+		writeJSONResponse(w, http.StatusUnprocessableEntity, ReloadResponse{
+			Version: s.CardVersion(),
+			Error: err.Error(),
+		})
+		return
+	}
+
+	response := ReloadResponse{Version: cardHandler.Version}
+	for _, problem := range problems {
+		response.Problems = append(response.Problems, problem.Error())
+	}
+	writeJSONResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"log"
+	"time"
+)
+
+// How far behind the game a delayed spectator is kept
+type embargo struct {
+	delay   time.Duration
+	actions int
+	// index of the next message in the room's history to send
+	next    int
+}
+
+// A message for delayed spectators, held back until
+// the embargo on it has passed
+type embargoedMessage struct {
+	message any
+	sentAt  time.Time
+}
+
+// Makes the embargo for a delayed spectator from the
+// server's settings
+func (s *Server) makeEmbargo() *embargo {
+	if s.settings.SpectatorDelay == 0 && s.settings.SpectatorDelayActions == 0 {
+		return &embargo{delay: DefaultSpectatorDelay}
+	}
+	return &embargo{
+		delay: s.settings.SpectatorDelay,
+		actions: s.settings.SpectatorDelayActions,
+	}
+}
+
+// Returns whether the message at this index of the history 
+// can be sent to the spectator. Once the game is over nothing
+// can be passed on to the players, so everything is released.
+// The game mutex has to be held while calling this
+func (r *Room) isReleased(user *User, index int) bool {
+	if r.Game.IsOver() {
+		return true
+	}
+
+	actionsSince := len(r.history) - 1 - index
+	return actionsSince >= user.embargo.actions &&
+		time.Since(r.history[index].sentAt) >= user.embargo.delay
+}
+
+// Adds the message to what delayed spectators will be sent, and
+// sends it to any who aren't held back. The game mutex has to be 
+// held while calling this
+func (r *Room) recordForDelayed(message any) {
+	r.history = append(r.history, embargoedMessage{
+		message: message,
+		sentAt: time.Now(),
+	})
+
+	for user := range r.delayedSpectators {
+		r.releaseEmbargoed(user)
+		r.scheduleRelease(user, len(r.history)-1)
+	}
+}
+
+// Sends the spectator everything in the history that's no longer
+// held back, in order. The game mutex has to be held while calling 
+// this
+func (r *Room) releaseEmbargoed(user *User) {
+	if !r.delayedSpectators[user] {
+		return
+	}
+
+	for user.embargo.next < len(r.history) && r.isReleased(user, user.embargo.next) {
+		err := user.writeJSON(r.history[user.embargo.next].message)
+		if err != nil {
+			log.Println("Error sending to delayed spectator: ", err)
+		}
+		user.embargo.next++
+	}
+}
+
+// Releases messages to the spectator once the time embargo on the 
+// message at this index runs out, since nothing else might happen 
+// in the game by then
+func (r *Room) scheduleRelease(user *User, index int) {
+	if user.embargo.delay == 0 {
+		return
+	}
+
+	wait := user.embargo.delay - time.Since(r.history[index].sentAt)
+	time.AfterFunc(wait, func() {
+		r.gameMutex.Lock()
+		defer r.gameMutex.Unlock()
+		r.releaseEmbargoed(user)
+	})
+}
+
+// Starts sending the delayed spectator the game from its start,
+// as each message's embargo runs out
+func (r *Room) watchDelayed(user *User) {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	r.delayedSpectators[user] = true
+	r.releaseEmbargoed(user)
+	for i := user.embargo.next; i < len(r.history); i++ {
+		r.scheduleRelease(user, i)
+	}
+}
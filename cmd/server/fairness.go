@@ -0,0 +1,72 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// The server's half of the randomness of a provably fair room. The
+// server commits to its seed before any player sends their entropy,
+// and reveals it once the game is over, so neither the server nor a
+// player can choose how the coin flip and shuffles come out
+type fairSeed struct {
+	serverSeed []byte
+	// indexed by the player's ID in the game
+	entropy    [PlayersToStartGame]string
+}
+
+func makeFairSeed() (*fairSeed, error) {
+	serverSeed := make([]byte, 32)
+	if _, err := rand.Read(serverSeed); err != nil {
+		return nil, err
+	}
+	return &fairSeed{serverSeed: serverSeed}, nil
+}
+
+// Returns the hex encoded SHA-256 of the server's seed
+func (f *fairSeed) commitment() string {
+	hash := sha256.Sum256(f.serverSeed)
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns the hex encoded server seed
+func (f *fairSeed) reveal() string {
+	return hex.EncodeToString(f.serverSeed)
+}
+
+// Returns the seed of the room, mixing the server's seed
+// with each player's entropy
+func (f *fairSeed) seed() int64 {
+	return mixSeed(f.serverSeed, f.entropy[:])
+}
+
+func mixSeed(serverSeed []byte, entropy []string) int64 {
+	hash := sha256.New()
+	hash.Write(serverSeed)
+	for _, el := range entropy {
+		// hashed separately so no entropy can run into the next
+		entropyHash := sha256.Sum256([]byte(el))
+		hash.Write(entropyHash[:])
+	}
+	return int64(binary.BigEndian.Uint64(hash.Sum(nil)))
+}
+
+// Checks the revealed server seed against the commitment, and
+// returns the seed the room was given with Room.SetSeed. The 
+// entropy is each player's, in the order of their game IDs
+func VerifyFairSeed(serverSeed string, commitment string, entropy []string) (int64, error) {
+	seedBytes, err := hex.DecodeString(serverSeed)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := sha256.Sum256(seedBytes)
+	if hex.EncodeToString(hash[:]) != commitment {
+		return 0, errors.New("server seed doesn't match the commitment")
+	}
+
+	return mixSeed(seedBytes, entropy), nil
+}
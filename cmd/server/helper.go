@@ -1,28 +1,53 @@
 package server
 
 import (
-  "fmt"
+  "crypto/rand"
+  "encoding/hex"
   "log"
   "strconv"
   "net/http"
 )
 
-func requestToRoomNumber(req *http.Request) uint8 {
+func requestToRoomID(req *http.Request) RoomID {
   roomString := req.URL.Query().Get("room")
+  log.Printf("Selected room: %s\n", roomString)
+  return RoomID(roomString)
+}
 
-  var roomNum uint8
-  if (roomString == "") {
-    roomNum = 255
-    log.Printf("No room selected, using default room (%d)\n", roomNum)
-  } else {
-    parsedRoomString, err := strconv.ParseInt(roomString, 10, 8)
-    if err != nil {
-      fmt.Printf("Error reading room number %s", roomString);
-    }
-    roomNum = uint8(parsedRoomString)
-    log.Printf("Selected room: %s\n", roomString)
+// Returns a random hex string made from the given number of bytes
+func makeToken(numberOfBytes int) (string, error) {
+  bytes := make([]byte, numberOfBytes)
+  if _, err := rand.Read(bytes); err != nil {
+    return "", err
   }
+  return hex.EncodeToString(bytes), nil
+}
 
-  return roomNum
+// Returns a random token a player can present to get 
+// back into their game after losing their connection
+func makeReconnectToken() (string, error) {
+  return makeToken(16)
 }
 
+// Returns a random token a player can give spectators to
+// let them see the game as the player does
+func makeFollowToken() (string, error) {
+  return makeToken(16)
+}
+
+// Returns the player a spectator asked to follow, and
+// false if they want the neutral view of the game
+func requestToFollowedPlayer(req *http.Request) (uint8, bool) {
+  followString := req.URL.Query().Get("follow")
+  if followString == "" {
+    return 0, false
+  }
+
+  followed, err := strconv.ParseUint(followString, 10, 8)
+  if err != nil || uint8(followed) >= PlayersToStartGame {
+    log.Printf("Can't follow player %s, using neutral view\n", followString)
+    return 0, false
+  }
+
+  return uint8(followed), true
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// A room waiting for players, as listed in the lobby
+type OpenRoom struct {
+	ID           RoomID    `json:"id"`
+	Players      uint8     `json:"players"`
+	ProvablyFair bool      `json:"provablyFair"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// How to connect to a room found through the lobby
+type JoinResponse struct {
+	ID         RoomID `json:"id"`
+	// The websocket path to connect to as a player, which 
+	// can take spectator=true as any room connection can
+	SocketPath string `json:"socketPath"`
+}
+
+// The path clients connect to the websocket on
+const SocketPath = "/socket"
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %s", err)
+	}
+}
+
+func joinResponse(id RoomID) JoinResponse {
+	return JoinResponse{
+		ID: id,
+		SocketPath: SocketPath + "?room=" + url.QueryEscape(string(id)),
+	}
+}
+
+// Makes a room with the options in the request body, 
+// and responds with how to join it
+func (s *Server) HandleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Rooms are created with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var options RoomOptions
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			http.Error(w, "Error parsing room options", http.StatusBadRequest)
+			return
+		}
+	}
+
+	room, err := s.Rooms.Create(func(id RoomID) (*Room, error) {
+		return s.makeRoom(id, options)
+	})
+	if err != nil {
+		log.Printf("Error creating room: %s", err)
+		http.Error(w, "Error creating room", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, joinResponse(room.ID))
+}
+
+// Lists the rooms waiting for players, oldest first
+func (s *Server) HandleOpenRooms(w http.ResponseWriter, r *http.Request) {
+	openRooms := make([]OpenRoom, 0)
+	for _, room := range s.Rooms.List() {
+		if !room.isOpen() {
+			continue
+		}
+		openRooms = append(openRooms, OpenRoom{
+			ID: room.ID,
+			Players: room.GetPlayersInRoom(),
+			ProvablyFair: room.Options.ProvablyFair,
+			CreatedAt: room.CreatedAt,
+		})
+	}
+
+	sort.Slice(openRooms, func(i, j int) bool {
+		return openRooms[i].CreatedAt.Before(openRooms[j].CreatedAt)
+	})
+
+	writeJSONResponse(w, http.StatusOK, openRooms)
+}
+
+// Checks the room given by the id query parameter can 
+// be joined, and responds with how to join it
+func (s *Server) HandleJoinRoom(w http.ResponseWriter, r *http.Request) {
+	id := RoomID(r.URL.Query().Get("id"))
+	room, ok := s.Rooms.Get(id)
+	if !ok {
+		http.Error(w, "No room with that ID", http.StatusNotFound)
+		return
+	}
+	if !room.isJoinable() {
+		http.Error(w, "Room is full", http.StatusConflict)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, joinResponse(room.ID))
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Used when ServerSettings.MatchmakingRatingRange isn't set
+const DefaultMatchmakingRatingRange = 200
+
+// How often queued connections are pinged, to find 
+// players who left before being matched
+const matchmakingPingInterval = 5 * time.Second
+
+// A player waiting in the matchmaking queue
+type matchRequest struct {
+	user      *User
+	// the sorted card sets the player's deck uses, or
+	// empty if they'll play against any sets
+	sets      string
+	rating    int
+	hasRating bool
+	// gets the room once the player is matched
+	matched   chan *Room
+}
+
+// Pairs queued players into rooms, oldest request first
+type Matchmaker struct {
+	mutex       sync.Mutex
+	waiting     []*matchRequest
+	ratingRange int
+}
+
+func NewMatchmaker(ratingRange int) *Matchmaker {
+	return &Matchmaker{
+		waiting: make([]*matchRequest, 0),
+		ratingRange: ratingRange,
+	}
+}
+
+// Returns the matchmaking request given by the sets and 
+// rating query parameters
+func requestToMatchRequest(req *http.Request, user *User) *matchRequest {
+	request := &matchRequest{
+		user: user,
+		matched: make(chan *Room, 1),
+	}
+
+	sets := make([]string, 0)
+	for _, set := range strings.Split(req.URL.Query().Get("sets"), ",") {
+		set = strings.TrimSpace(set)
+		if set != "" && !containsString(sets, set) {
+			sets = append(sets, set)
+		}
+	}
+	sort.Strings(sets)
+	request.sets = strings.Join(sets, ",")
+
+	if ratingString := req.URL.Query().Get("rating"); ratingString != "" {
+		rating, err := strconv.Atoi(ratingString)
+		if err != nil {
+			log.Printf("Error reading rating %s, matching with any rating\n", ratingString)
+		} else {
+			request.rating, request.hasRating = rating, true
+		}
+	}
+
+	return request
+}
+
+func containsString(list []string, el string) bool {
+	for _, item := range list {
+		if item == el {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the two players can be put in a game together.
+// Anything a player leaves out doesn't restrict who they play
+func (m *Matchmaker) canMatch(a *matchRequest, b *matchRequest) bool {
+	if a.sets != "" && b.sets != "" && a.sets != b.sets {
+		return false
+	}
+	if a.hasRating && b.hasRating {
+		difference := a.rating - b.rating
+		if difference < 0 {
+			difference = -difference
+		}
+		return difference <= m.ratingRange
+	}
+	return true
+}
+
+// Matches the request with a waiting player if there's one it
+// can play, putting them both in a room made by makeRoom, or 
+// else adds it to the queue and returns nil. If the players 
+// can't be put in the room, it's removed with removeRoom and
+// the waiting player is sent nil
+func (m *Matchmaker) enqueue(request *matchRequest, makeRoom func() (*Room, error), removeRoom func(*Room)) (*Room, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, waiting := range m.waiting {
+		if !m.canMatch(waiting, request) {
+			continue
+		}
+
+		room, err := makeRoom()
+		if err != nil {
+			return nil, err
+		}
+
+		// the player who waited longest is the first player
+		m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+		for _, user := range []*User{waiting.user, request.user} {
+			if err := room.InitPlayer(user); err != nil {
+				// neither player is left waiting on a room
+				// that can't be played in
+				removeRoom(room)
+				waiting.matched <- nil
+				return nil, err
+			}
+			room.addConnection(user)
+		}
+
+		waiting.matched <- room
+		return room, nil
+	}
+
+	m.waiting = append(m.waiting, request)
+	return nil, nil
+}
+
+// Takes the request out of the queue, returning false if
+// it had already been matched
+func (m *Matchmaker) remove(request *matchRequest) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, waiting := range m.waiting {
+		if waiting == request {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of players waiting to be matched
+func (m *Matchmaker) Waiting() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.waiting)
+}
+
+// Waits for the request to be matched, returning nil if the
+// player leaves first. The connection is read from while waiting,
+// so a closed connection is noticed and pings are answered. The
+// read still going when the player is matched is left for the 
+// room to finish, since it gets the player's first message
+func (m *Matchmaker) waitForMatch(ws *websocket.Conn, request *matchRequest) *Room {
+	ticker := time.NewTicker(matchmakingPingInterval)
+	defer ticker.Stop()
+
+	reads := make(chan readResult, 1)
+	read := func() {
+		_, p, err := ws.ReadMessage()
+		reads <- readResult{p, err}
+	}
+	go read()
+
+	for {
+		var err error
+		select {
+		case room := <-request.matched:
+			request.user.pendingRead = reads
+			return room
+		case result := <-reads:
+			if result.err == nil {
+				// nothing is expected from the client until
+				// they're matched
+				go read()
+				continue
+			}
+			err = result.err
+			// the room gets the same error when it reads
+			reads <- result
+		case <-ticker.C:
+			err = ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+			if err == nil {
+				continue
+			}
+		}
+
+		log.Printf("Client [%p] left the matchmaking queue: %s\n", ws, err)
+		if m.remove(request) {
+			return nil
+		}
+		// matched while they were leaving, so the room
+		// finds out they've gone once it reads from them
+		room := <-request.matched
+		request.user.pendingRead = reads
+		return room
+	}
+}
+
+// Queues the player until there's an opponent for them, and 
+// then runs the game as if they'd both joined a new room
+func (s *Server) HandleMatchmake(res http.ResponseWriter, req *http.Request) {
+	ws, err := upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		log.Printf("Error upgrading: %s", err)
+		return
+	}
+
+	user := User{Conn: ws}
+	request := requestToMatchRequest(req, &user)
+
+	room, err := s.matchmaker.enqueue(request, func() (*Room, error) {
+		return s.Rooms.Create(func(id RoomID) (*Room, error) {
+			// matched rooms are only for the players in them
+			return s.makeRoom(id, RoomOptions{Private: true})
+		})
+	}, func(room *Room) {
+		s.Rooms.Remove(room.ID)
+	})
+	if err != nil {
+		log.Printf("Error matching players: %s", err)
+		ws.Close()
+		return
+	}
+
+	if room == nil {
+		log.Printf("Client [%p] is waiting for a match\n", ws)
+		room = s.matchmaker.waitForMatch(ws, request)
+		if room == nil {
+			ws.Close()
+			return
+		}
+	}
+
+	s.runInRoom(ws, room, &user)
+}
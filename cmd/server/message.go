@@ -13,13 +13,41 @@ type Message[T any] struct {
 	Timestamp   string                    `json:"timestamp"`
 }
 
+type Outcome string
+const (
+  OutcomeNone = Outcome("")
+  OutcomeWin  = Outcome("WIN")
+  OutcomeLoss = Outcome("LOSS")
+  OutcomeDraw = Outcome("DRAW")
+)
+
 // Message Content Types
 type SetupContent struct {
-  Deck []uint `json:"deck"`
+  // Each card is {"set": ..., "id": ...}, or just the card's
+  // number for a card in the default set
+  Deck    []gamemanager.CardIdentity  `json:"deck"`
+  // In provably fair rooms, mixed into the seed of the
+  // coin flip and shuffles
+  Entropy string                      `json:"entropy,omitempty"`
 }
 type SetupResponse struct {
-  MyDeck  []uint `json:"myDeck"`
-  OppDeck []uint `json:"oppDeck"`
+  MyDeck          []uint `json:"myDeck"`
+  OppDeck         []uint `json:"oppDeck"`
+  // Presented as /socket?reconnect=<token> to get back
+  // into the game after losing the connection
+  ReconnectToken  string `json:"reconnectToken"`
+  // Given to spectators, who present it as
+  // /socket?spectator=true&follow=<player>&token=<token>
+  // to see the game as this player does. It can't be
+  // used to reconnect
+  FollowToken     string `json:"followToken"`
+}
+// Sent to players in provably fair rooms before their setup
+// message, so the server can't choose its seed after seeing
+// their entropy
+type CommitmentContent struct {
+  // Hex encoded SHA-256 of the server's seed
+  Commitment string `json:"commitment"`
 }
 type CoinFlipContent struct {
   IsChoosingFlip bool `json:"isChoosingFlip"`
@@ -33,11 +61,25 @@ type StartGameContent struct {
 type StartGameContentChoice struct {
   First bool `json:"first"`
 }
+type GameOverContent struct {
+  // From the perspective of the user receiving this, 
+  // and OutcomeNone for spectators
+  Outcome Outcome                 `json:"outcome"`
+  // The index of the player who won, or -1 on a draw
+  Winner  int                     `json:"winner"`
+  Reason  gamemanager.EndReason   `json:"reason"`
+  // In provably fair rooms, the hex encoded server seed,
+  // so players can check it against the commitment and
+  // rebuild the coin flip and shuffles
+  ServerSeed string               `json:"serverSeed,omitempty"`
+}
 // gamemanager.UpdateInfo also counts as one of these
 // gamemanager.Action also counts as one of these
+// gamemanager.GameError also counts as one of these
+// gamemanager.Snapshot also counts as one of these
 //
 
 func (params *Message[T]) String() string {
   contentString := fmt.Sprint(params.Content) 
-  return fmt.Sprintf("[Content: %s, Type: %s, Time: %s]\n", contentString, params.MessageType, params.Timestamp)
+  return fmt.Sprintf("[Content: %s, Type: %v, Time: %s]\n", contentString, params.MessageType, params.Timestamp)
 }
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// An opaque ID for a room, picked at random when it's made
+type RoomID string
+
+// Used when ServerSettings.IdleRoomTimeout isn't set
+const DefaultIdleRoomTimeout = 10 * time.Minute
+
+// Games in progress are kept this many times the idle timeout
+// after everyone leaves, since the players can still reconnect
+const startedGameIdleFactor = 6
+
+// Options for a room made through the lobby
+type RoomOptions struct {
+	ProvablyFair bool `json:"provablyFair"`
+	// Private rooms aren't listed as open, and can only
+	// be joined by someone given the ID
+	Private      bool `json:"private"`
+}
+
+// Holds every room on the server, and removes rooms once 
+// they're finished or nobody has been in them for a while
+type RoomRegistry struct {
+	mutex       sync.Mutex
+	rooms       map[RoomID]*Room
+	idleTimeout time.Duration
+}
+
+func NewRoomRegistry(idleTimeout time.Duration) *RoomRegistry {
+	return &RoomRegistry{
+		rooms: make(map[RoomID]*Room),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// Returns the room with this ID, if there is one
+func (rr *RoomRegistry) Get(id RoomID) (*Room, bool) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	room, ok := rr.rooms[id]
+	return room, ok
+}
+
+// Makes a room with a new random ID. Rooms are only made
+// here, so connecting with an ID nobody was given fails
+func (rr *RoomRegistry) Create(makeRoom func(RoomID) (*Room, error)) (*Room, error) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.collect()
+
+	var id RoomID
+	for id == "" || rr.rooms[id] != nil {
+		token, err := makeToken(8)
+		if err != nil {
+			return nil, err
+		}
+		id = RoomID(token)
+	}
+
+	room, err := makeRoom(id)
+	if err != nil {
+		return nil, err
+	}
+	rr.rooms[id] = room
+	return room, nil
+}
+
+// Removes the room with this ID, if there is one
+func (rr *RoomRegistry) Remove(id RoomID) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	delete(rr.rooms, id)
+}
+
+// Returns every room, in no particular order
+func (rr *RoomRegistry) List() []*Room {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.collect()
+
+	rooms := make([]*Room, 0, len(rr.rooms))
+	for _, room := range rr.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func (rr *RoomRegistry) Len() int {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	return len(rr.rooms)
+}
+
+// Removes rooms nobody is connected to that have either finished
+// their game or gone without anyone for longer than the idle 
+// timeout. The registry's mutex has to be held while calling this
+func (rr *RoomRegistry) collect() {
+	for id, room := range rr.rooms {
+		if room.isAbandoned(rr.idleTimeout) {
+			delete(rr.rooms, id)
+		}
+	}
+}
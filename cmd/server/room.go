@@ -1,12 +1,14 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/Zarone/CardGameServer/cmd/gamemanager"
 	"github.com/gorilla/websocket"
@@ -15,6 +17,69 @@ import (
 type User struct {
 	Conn *websocket.Conn
 	IsSpectator bool
+	writeMutex sync.Mutex
+	reconnectToken string
+	// given to spectators the player wants to see their hand,
+	// which unlike the reconnect token can't take their seat
+	followToken string
+
+	// spectators following a player see the game as that 
+	// player does, rather than with both hands hidden. This
+	// takes the player's follow token, so only someone the
+	// player gave it to can see their hand
+	isFollowing bool
+	followedPlayer uint8
+	presentedToken string
+
+	// delayed spectators see both hands, but only once
+	// the embargo on each message has passed
+	embargo *embargo
+
+	// a read started while the user waited for a match, which 
+	// the room takes over so the connection only has one reader
+	pendingRead chan readResult
+}
+
+type readResult struct {
+	p   []byte
+	err error
+}
+
+// Reads the next message from the user's connection, finishing
+// the read started while they waited for a match if there is one
+func (u *User) readMessage() ([]byte, error) {
+	if u.pendingRead != nil {
+		result := <-u.pendingRead
+		u.pendingRead = nil
+		return result.p, result.err
+	}
+	_, p, err := u.Conn.ReadMessage()
+	return p, err
+}
+
+// Writes v to the user's connection. Messages to a player can be
+// sent from either player's goroutine, so writes are serialized
+func (u *User) writeJSON(v any) error {
+	u.writeMutex.Lock()
+	defer u.writeMutex.Unlock()
+	return u.Conn.WriteJSON(v)
+}
+
+// Returns the connection the user is currently bound to
+func (u *User) getConn() *websocket.Conn {
+	u.writeMutex.Lock()
+	defer u.writeMutex.Unlock()
+	return u.Conn
+}
+
+// Binds the user to a new connection, closing the old one
+func (u *User) rebind(conn *websocket.Conn) {
+	u.writeMutex.Lock()
+	defer u.writeMutex.Unlock()
+	if u.Conn != nil {
+		u.Conn.Close()
+	}
+	u.Conn = conn
 }
 
 type CoinFlip uint8
@@ -31,39 +96,95 @@ const (
   DESC_HEADS_OR_TAILS_CHOSEN    = RoomDescription("Heads/Tails Chosen...")
   DESC_INITIAL_STATE_TO_CLIENT  = RoomDescription("Initial Game State Sent to Clients...")
 	DESC_JUST_CREATED							= RoomDescription("Just Created...")
+	DESC_GAME_FINISHED            = RoomDescription("Game Finished...")
 )
 
 const PlayersToStartGame uint8 = 2
 
+// How many decks a player can send before the room gives up on them
+const MaxDeckAttempts = 5
+
+// Returned when a player leaves before the game starts
+var errOpponentLeft = &gamemanager.GameError{
+	Code: gamemanager.ErrorCodeOpponentLeft,
+	Message: "your opponent left before the game started",
+}
+
+var errCantFollow = &gamemanager.GameError{
+	Code: gamemanager.ErrorCodeUnknownToken,
+	Message: "following a player takes their follow token, watching with both hands hidden instead",
+}
+
 type Room struct {
 	Connections             map[*User]bool
+	connectionsMutex        sync.Mutex
+	// when someone last joined or left the room
+	lastActive              time.Time
 	PlayerToGamePlayerID    map[*User]uint8
 	Game                    *gamemanager.Game
 	ReadyPlayersMutex       sync.Mutex
+	gameMutex               sync.Mutex
+	spectators              map[*User]bool
+	delayedSpectators       map[*User]bool
+	// everything sent to delayed spectators, from the start of the game
+	history                 []embargoedMessage
 	ReadyPlayers            []*User
 	barrier                 *Barrier
 	ExpectingCoinFlip       CoinFlip
-	RoomNumber              uint8
+	// random source for the coin flip
+	rng                     *rand.Rand
+	// nil unless the room is provably fair
+	fair                    *fairSeed
+	ID                      RoomID
+	Options                 RoomOptions
+	CreatedAt               time.Time
+	// read by the registry and the lobby while the players'
+	// goroutines move the room through setup, so it's read
+	// and written through Description and setDescription
 	RoomDescription         RoomDescription
+	descriptionMutex        sync.Mutex
+	// copied out of the game after every action, so the room
+	// listings don't wait on the game mutex, which is held
+	// while the players are written to
+	turn                    turnSummary
+	turnMutex               sync.Mutex
 }
 
-func MakeRoom(roomNumber uint8, cardHandler *gamemanager.CardHandler) *Room {
+func MakeRoom(id RoomID, cardHandler *gamemanager.CardHandler) *Room {
+	now := time.Now()
 	ret := &Room{
 		PlayerToGamePlayerID: make(map[*User]uint8),
 		Connections: make(map[*User]bool),
+		spectators: make(map[*User]bool),
+		delayedSpectators: make(map[*User]bool),
 		Game: gamemanager.MakeGame(cardHandler),
 		ReadyPlayers: make([]*User, 0),
 		ExpectingCoinFlip: CoinFlipUnset,
-		RoomNumber: roomNumber,
+		ID: id,
+		CreatedAt: now,
+		lastActive: now,
 		RoomDescription: DESC_JUST_CREATED,
 		barrier: NewBarrier(int(PlayersToStartGame)),
+		turn: turnSummary{activePlayer: -1},
 	}
+	ret.SetSeed(rand.Int63())
 	return ret
 }
 
+// Seeds the room's coin flip and the game's shuffles, so that
+// the same seed always gives the same game for the same actions.
+// Has to be called before the game starts to take effect
+func (r *Room) SetSeed(seed int64) {
+	r.rng = rand.New(rand.NewSource(seed))
+	r.Game.SetSeed(r.rng.Int63())
+}
+
 // GetPlayersInRoom returns the number of players in the room,
 // excluding spectators
 func (r *Room) GetPlayersInRoom() uint8 {
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
 	var num uint8 = 0
 
 	for player, isActive := range r.Connections {
@@ -83,14 +204,113 @@ func (r *Room) InitPlayer(user *User) error {
 		return errors.New("too many players")
 	}
 
+	token, err := makeReconnectToken()
+	if err != nil {
+		return err
+	}
+	user.reconnectToken = token
+
+	token, err = makeFollowToken()
+	if err != nil {
+		return err
+	}
+	user.followToken = token
+
 	r.PlayerToGamePlayerID[user] = r.Game.AddPlayer() 
 	r.ReadyPlayers = append(r.ReadyPlayers, user)
-	
 
 	return nil
 }
 
+// Returns whether the user is one of the room's players
+func (r *Room) isPlayer(user *User) bool {
+	r.ReadyPlayersMutex.Lock()
+	defer r.ReadyPlayersMutex.Unlock()
+
+	_, isPlayer := r.PlayerToGamePlayerID[user]
+	return isPlayer
+}
+
+// Marks the user as connected to the room
+func (r *Room) addConnection(user *User) {
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
+	r.Connections[user] = true
+	r.lastActive = time.Now()
+}
+
+// Returns the users currently connected to the room
+func (r *Room) getActiveUsers() []*User {
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
+	users := make([]*User, 0, len(r.Connections))
+	for user, isActive := range r.Connections {
+		if isActive {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// Returns what the room is doing, like RoomDescription, but
+// safe to call while the players' goroutines move it along
+func (r *Room) Description() RoomDescription {
+	r.descriptionMutex.Lock()
+	defer r.descriptionMutex.Unlock()
+	return r.RoomDescription
+}
+
+func (r *Room) setDescription(description RoomDescription) {
+	r.descriptionMutex.Lock()
+	defer r.descriptionMutex.Unlock()
+	r.RoomDescription = description
+}
+
+// Returns whether nobody is in the room, and either its game
+// is over or nobody has been in it for longer than the timeout.
+// A game in progress is kept longer, for its players to reconnect to
+func (r *Room) isAbandoned(idleTimeout time.Duration) bool {
+	description := r.Description()
+	if description == DESC_INITIAL_STATE_TO_CLIENT {
+		idleTimeout *= startedGameIdleFactor
+	}
+
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
+	for _, isActive := range r.Connections {
+		if isActive {
+			return false
+		}
+	}
+	return description == DESC_GAME_FINISHED || 
+		time.Since(r.lastActive) > idleTimeout
+}
+
+// Returns whether the room is listed in the lobby
+// as waiting for players
+func (r *Room) isOpen() bool {
+	return !r.Options.Private && r.isJoinable()
+}
+
+// Returns whether a player can still take a seat in the room,
+// which is only until the game has all its players
+func (r *Room) isJoinable() bool {
+	r.ReadyPlayersMutex.Lock()
+	seated := len(r.ReadyPlayers)
+	r.ReadyPlayersMutex.Unlock()
+
+	return r.Description() == DESC_JUST_CREATED &&
+		seated < int(PlayersToStartGame) &&
+		r.GetPlayersInRoom() < PlayersToStartGame
+}
+
 func (r *Room) String() string {
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
 	str := ""
 	for user, isPresent := range r.Connections {
 		str += fmt.Sprintf("[ConnectionPointer: %p, isPresent: %t, isSpectator: %t], ", user.Conn, isPresent, user.IsSpectator)
@@ -100,9 +320,22 @@ func (r *Room) String() string {
 
 // Sends the deck list to the game state manager to 
 // set it up. Returns the gameID list in the same
-// order as the cardID list
-func (r *Room) initGameData(u *User, deck []uint) {
-  r.Game.SetupPlayer(r.PlayerToGamePlayerID[u], deck)
+// order as the cardID list. The players take turns, so
+// the game IDs their decks get don't depend on whose
+// deck came in first, and a seed always replays the same
+func (r *Room) initGameData(u *User, deck []gamemanager.CardIdentity) error {
+  playerID := r.PlayerToGamePlayerID[u]
+  for turn := uint8(0); turn < PlayersToStartGame; turn++ {
+    if turn == playerID {
+      if err := r.Game.SetupPlayer(playerID, deck); err != nil {
+        return err
+      }
+    }
+    if !r.barrier.Wait() {
+      return errOpponentLeft
+    }
+  }
+  return nil
 }
 
 func (r *Room) getInitData(u *User) Message[SetupResponse] {
@@ -111,6 +344,8 @@ func (r *Room) getInitData(u *User) Message[SetupResponse] {
     Content: SetupResponse{
       MyDeck: *myDeck,
       OppDeck: *oppDeck,
+      ReconnectToken: u.reconnectToken,
+      FollowToken: u.followToken,
     },
     MessageType: gamemanager.MessageTypeSetup,
     Timestamp: timestamp(),
@@ -118,7 +353,9 @@ func (r *Room) getInitData(u *User) Message[SetupResponse] {
 }
 
 // Takes info from client regarding their deck list 
-// and such
+// and such. A deck that breaks the game's deck format is
+// sent back with an error, and the client can send another,
+// up to MaxDeckAttempts decks in all
 func (r *Room) readSetupParams(user *User) (*Message[SetupContent], error) {
 	if (user.IsSpectator) { return nil, nil }
 
@@ -127,41 +364,173 @@ func (r *Room) readSetupParams(user *User) (*Message[SetupContent], error) {
 	// is ready to setup
 	defer r.wait(DESC_PARAMETERS_READ)
 
-	// read in a message
-	_, p, err := user.Conn.ReadMessage()
-	if err != nil {
-		return nil, fmt.Errorf("error Reading Message {%s}", err)
+	for attempt := 1; ; attempt++ {
+		// read in a message
+		p, err := user.readMessage()
+		if err != nil {
+			return nil, fmt.Errorf("error Reading Message {%s}", err)
+		}
+		
+		var params Message[SetupContent] 
+		if err := json.Unmarshal(p, &params); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %s", err)
+		} else if (params.MessageType != gamemanager.MessageTypeSetup) {
+			return nil, fmt.Errorf("error setting up, message type is %v", params.MessageType)
+		}
+
+		if err := r.Game.CheckDeck(params.Content.Deck); err != nil {
+			if err := r.sendError(user, gamemanager.ToGameError(err)); err != nil {
+				return nil, err
+			}
+			if attempt >= MaxDeckAttempts {
+				return nil, fmt.Errorf("error setting up, no legal deck after %d attempts", attempt)
+			}
+			continue
+		}
+
+		if r.fair != nil {
+			r.fair.entropy[r.PlayerToGamePlayerID[user]] = params.Content.Entropy
+		}
+
+		return &params, nil
 	}
-	
-	var params Message[SetupContent] 
-	if err := json.Unmarshal(p, &params); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %s", err)
-	} else if (params.MessageType != gamemanager.MessageTypeSetup) {
-		return nil, fmt.Errorf("error setting up, message type is %v", params.MessageType)
+}
+
+// Makes the room provably fair, seeding it once the
+// players have sent their entropy
+func (r *Room) makeFair() error {
+	fair, err := makeFairSeed()
+	if err != nil {
+		return err
 	}
+	r.fair = fair
+	return nil
+}
 
-	return &params, nil
+// Sends a player in a provably fair room the commitment to
+// the server's seed
+func (r *Room) sendCommitment(user *User) error {
+	if r.fair == nil || user.IsSpectator {
+		return nil
+	}
+	return user.writeJSON(Message[CommitmentContent]{
+		Content: CommitmentContent{
+			Commitment: r.fair.commitment(),
+		},
+		MessageType: gamemanager.MessageTypeCommitment,
+		Timestamp: timestamp(),
+	})
 }
 
 // Attempts to remove connection to the room specified by the request
 func (r *Room) RemoveFromRoom(user *User) error {
+	r.connectionsMutex.Lock()
 	if len(r.Connections) == 0 {
+		r.connectionsMutex.Unlock()
 		return errors.New("Error removing from room")
 	}
 
-	if (!user.IsSpectator) {
-		fmt.Println("Implement player disconnect")
+	r.Connections[user] = false
+	r.lastActive = time.Now()
+	r.connectionsMutex.Unlock()
+
+	// the game can't start without this player, so don't 
+	// leave the other player waiting on them
+	if r.isPlayer(user) && !r.hasGameStarted() {
+		r.barrier.Break()
 	}
 
-	r.Connections[user] = false
   return nil
 }
 
-func (r *Room) readForActions(ws *websocket.Conn) (gamemanager.Action, error) {
+// Removes the user from the room, unless they've since
+// reconnected on a different connection
+func (r *Room) disconnect(user *User, conn *websocket.Conn) {
+	if user.getConn() != conn {
+		return
+	}
+	r.RemoveFromRoom(user)
+}
+
+// Returns whether both players have been sent the
+// initial game state
+func (r *Room) hasGameStarted() bool {
+	description := r.Description()
+	return description == DESC_INITIAL_STATE_TO_CLIENT ||
+		description == DESC_GAME_FINISHED
+}
+
+// Returns the player with the given reconnect token, or
+// nil if no player in this room has it
+func (r *Room) findPlayerByToken(token string) *User {
+	r.ReadyPlayersMutex.Lock()
+	defer r.ReadyPlayersMutex.Unlock()
+
+	for _, user := range r.ReadyPlayers {
+		if subtle.ConstantTimeCompare([]byte(user.reconnectToken), []byte(token)) == 1 {
+			return user
+		}
+	}
+	return nil
+}
+
+// Returns whether token is the follow token of the
+// player with the given ID
+func (r *Room) hasFollowToken(id uint8, token string) bool {
+	r.ReadyPlayersMutex.Lock()
+	defer r.ReadyPlayersMutex.Unlock()
+
+	if token == "" || int(id) >= len(r.ReadyPlayers) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.ReadyPlayers[id].followToken), []byte(token)) == 1
+}
+
+// Binds a player who lost their connection to a new one, and
+// sends them the game as they left it
+func (r *Room) Reconnect(user *User, conn *websocket.Conn) error {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	if !r.hasGameStarted() {
+		return errors.New("can't reconnect before the game has started")
+	}
+
+	user.rebind(conn)
+	r.addConnection(user)
+
+	return r.sendSnapshot(user)
+}
+
+// Sends the user the whole game as they see it. The
+// game mutex has to be held while calling this
+func (r *Room) sendSnapshot(user *User) error {
+	var snapshot *gamemanager.Snapshot
+	if user.IsSpectator && user.isFollowing {
+		snapshot = r.Game.GetSnapshot(user.followedPlayer)
+	} else if user.IsSpectator {
+		snapshot = r.Game.GetNeutralSnapshot()
+	} else {
+		snapshot = r.Game.GetSnapshot(r.PlayerToGamePlayerID[user])
+	}
+	err := user.writeJSON(
+		Message[gamemanager.Snapshot]{
+			Timestamp: timestamp(),
+			Content: *snapshot,
+			MessageType: gamemanager.MessageTypeSnapshot,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("Error writing message %s", err)
+	}
+	return nil
+}
+
+func (r *Room) readForActions(ws *websocket.Conn) (Message[gamemanager.Action], error) {
 	// read in a message
 	_, p, err := ws.ReadMessage()
 	if err != nil {
-		return gamemanager.Action{}, fmt.Errorf("Error Reading Message {%s}", err)
+		return Message[gamemanager.Action]{}, fmt.Errorf("Error Reading Message {%s}", err)
 	}
 
 	// print out that message for clarity
@@ -171,14 +540,17 @@ func (r *Room) readForActions(ws *websocket.Conn) (gamemanager.Action, error) {
   var action Message[gamemanager.Action];
   err = json.Unmarshal(p, &action)
   if err != nil {
-    return gamemanager.Action{}, fmt.Errorf("Error Getting Game Action from Message")
+    return Message[gamemanager.Action]{}, &gamemanager.GameError{
+      Code: gamemanager.ErrorCodeMalformedMessage,
+      Message: fmt.Sprintf("Error Getting Game Action from Message: %s", err),
+    }
   }
 
-	return action.Content, nil
+	return action, nil
 }
 
 func (r *Room) sendUpdateInfo(user *User, info *gamemanager.UpdateInfo) error {
-  err := user.Conn.WriteJSON(
+  err := user.writeJSON(
     Message[gamemanager.UpdateInfo]{
       Timestamp: timestamp(), 
       Content: *info, 
@@ -191,23 +563,249 @@ func (r *Room) sendUpdateInfo(user *User, info *gamemanager.UpdateInfo) error {
   return nil
 }
 
+// Sends spectators the update from the viewpoint they
+// chose, given the updates sent to each player. The game 
+// mutex has to be held while calling this
+func (r *Room) sendToSpectators(infos [PlayersToStartGame]*gamemanager.UpdateInfo) {
+	neutralInfo := r.Game.ToSpectatorInfo(infos[0])
+
+	for user := range r.spectators {
+		info := neutralInfo
+		if user.isFollowing {
+			info = infos[user.followedPlayer]
+		}
+
+		// a spectator that can't keep up shouldn't hold up the game
+		if err := r.sendUpdateInfo(user, info); err != nil {
+			log.Println("Error sending to spectator: ", err)
+		}
+	}
+}
+
+func (r *Room) sendError(user *User, gameError *gamemanager.GameError) error {
+	err := user.writeJSON(
+		Message[gamemanager.GameError]{
+			Timestamp: timestamp(),
+			Content: *gameError,
+			MessageType: gamemanager.MessageTypeError,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("Error writing message %s", err)
+	}
+	return nil
+}
+
 func (r *Room) processAction(user *User, action *gamemanager.Action) (*gamemanager.UpdateInfo, *gamemanager.UpdateInfo, error) {
 	return r.Game.ProcessAction(r.PlayerToGamePlayerID[user], action)
 }
 
+// Processes the action and sends the results to both players.
+// The game mutex is held until both updates are sent, so actions
+// from the two players are applied one at a time and their 
+// updates reach the clients in the same order
+func (r *Room) handleAction(user *User, action *gamemanager.Action) error {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	id := r.PlayerToGamePlayerID[user]
+	isConceding := gamemanager.ActionType(action.ActionType) == gamemanager.ActionTypeConcede
+	if !r.Game.IsPlayersTurn(id) && !isConceding && !r.Game.IsOver() {
+		return r.sendError(user, &gamemanager.GameError{
+			Code: gamemanager.ErrorCodeNotYourTurn,
+			Message: "it is your opponent's turn",
+		})
+	}
+
+	info, oppInfo, err := r.processAction(user, action)
+	r.updateTurn()
+	if err != nil {
+		log.Println("Error processing game action: ", err)
+		err = r.sendError(user, gamemanager.ToGameError(err))
+
+		// an error that isn't the player's ends the game
+		if r.Game.IsOver() {
+			r.finishGame()
+		}
+		return err
+	}
+
+	err = r.sendUpdateInfo(user, info)
+	if err != nil {
+		return err
+	}
+
+	// the opponent might be disconnected, in which case
+	// they'll get a snapshot when they reconnect
+	err = r.sendUpdateInfo(r.ReadyPlayers[1-id], oppInfo)
+	if err != nil {
+		log.Println("Error sending to opponent: ", err)
+	}
+
+	var infos [PlayersToStartGame]*gamemanager.UpdateInfo
+	infos[id] = info
+	infos[1-id] = oppInfo
+	r.sendToSpectators(infos)
+	r.recordForDelayed(Message[gamemanager.UpdateInfo]{
+		Content: *r.Game.ToOmniscientInfo(infos[0]),
+		MessageType: gamemanager.MessageTypeGameplay,
+		Timestamp: timestamp(),
+	})
+
+	if r.Game.IsOver() {
+		r.finishGame()
+	}
+	return nil
+}
+
+// Tells players and spectators how the game ended
+func (r *Room) finishGame() {
+	if r.Description() == DESC_GAME_FINISHED {
+		return
+	}
+	r.setDescription(DESC_GAME_FINISHED)
+
+	result := r.Game.Result
+	winner := int(result.Winner)
+	if result.IsDraw {
+		winner = -1
+	}
+
+	serverSeed := ""
+	if r.fair != nil {
+		serverSeed = r.fair.reveal()
+	}
+
+	for _, user := range r.getActiveUsers() {
+		// delayed spectators find out when they catch up
+		if user.embargo != nil {
+			continue
+		}
+
+		outcome := OutcomeNone
+		if !user.IsSpectator {
+			if result.IsDraw {
+				outcome = OutcomeDraw
+			} else if r.PlayerToGamePlayerID[user] == result.Winner {
+				outcome = OutcomeWin
+			} else {
+				outcome = OutcomeLoss
+			}
+		}
+
+		err := user.writeJSON(Message[GameOverContent]{
+			Content: GameOverContent{
+				Outcome: outcome,
+				Winner: winner,
+				Reason: result.Reason,
+				ServerSeed: serverSeed,
+			},
+			MessageType: gamemanager.MessageTypeGameOver,
+			Timestamp: timestamp(),
+		})
+		if err != nil {
+			log.Println("Error sending game over: ", err)
+		}
+	}
+
+	r.recordForDelayed(Message[GameOverContent]{
+		Content: GameOverContent{
+			Outcome: OutcomeNone,
+			Winner: winner,
+			Reason: result.Reason,
+			ServerSeed: serverSeed,
+		},
+		MessageType: gamemanager.MessageTypeGameOver,
+		Timestamp: timestamp(),
+	})
+}
+
+// Returns whether the initial game state has been dealt,
+// since there's nothing to show before then. The game
+// mutex has to be held while calling this
+func (r *Room) hasInitialState() bool {
+	return r.Game.TurnNumber > 0
+}
+
+// Sends the user a snapshot if the game has started. Delayed
+// spectators rebuild the game from what they've been sent instead,
+// since a snapshot would show them the game as it is now
+func (r *Room) handleSnapshotRequest(user *User) error {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	if !r.hasInitialState() || user.embargo != nil {
+		return nil
+	}
+	return r.sendSnapshot(user)
+}
+
+// Starts sending the spectator every update. Spectators joining 
+// mid-game start from a snapshot, which is sent under the same
+// lock so no update is missed or sent twice
+func (r *Room) watch(user *User) error {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	if user.isFollowing && !r.hasFollowToken(user.followedPlayer, user.presentedToken) {
+		log.Printf("Spectator [%p] can't follow player %d without their token\n", user, user.followedPlayer)
+		user.isFollowing = false
+		if err := r.sendError(user, errCantFollow); err != nil {
+			return err
+		}
+	}
+
+	r.spectators[user] = true
+	if !r.hasInitialState() {
+		return nil
+	}
+	return r.sendSnapshot(user)
+}
+
+// Stops sending the spectator updates
+func (r *Room) unwatch(user *User) {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
+	delete(r.spectators, user)
+	delete(r.delayedSpectators, user)
+}
+
 func (r *Room) spectatorLoop(user *User) {
+	defer r.unwatch(user)
+
+	if user.embargo != nil {
+		r.watchDelayed(user)
+	} else if err := r.watch(user); err != nil {
+		log.Println("Stopped sending to user, endcode: ", err)
+		return
+	}
+
 	for {
-		_, err := r.readForActions(user.Conn)
-		if (err != nil) {
+		msg, err := r.readForActions(user.Conn)
+
+		var gameError *gamemanager.GameError
+		if errors.As(err, &gameError) {
+			continue
+		} else if (err != nil) {
 			log.Println("Stopped reading from user, endcode: ", err)
 			break
 		}
+
+		if msg.MessageType == gamemanager.MessageTypeSnapshot {
+			err = r.handleSnapshotRequest(user)
+			if err != nil {
+				log.Println("Stopped sending to user, endcode: ", err)
+				break
+			}
+		}
 	}
 }
 
 // returns (true, nil) if player 1 is going first
 func (r *Room) askTurnOrder() (bool, error) {
-	isHeads := rand.Intn(2) == 1 
+	isHeads := r.rng.Intn(2) == 1 
+	r.Game.RecordCoinFlip(r.ExpectingCoinFlip == CoinFlipHead, isHeads)
 
 	var userChoosingFlip *User
 	var userWaiting *User
@@ -221,14 +819,14 @@ func (r *Room) askTurnOrder() (bool, error) {
 		userWaiting = r.ReadyPlayers[0]
 	}
 
-	userChoosingFlip.Conn.WriteJSON(Message[StartGameContent]{
+	userChoosingFlip.writeJSON(Message[StartGameContent]{
 		Content: StartGameContent {
 			IsChoosingTurnOrder: true,
 		},
 		MessageType: gamemanager.MessageTypeHeadsOrTails,
 		Timestamp: timestamp(),
 	})
-	userWaiting.Conn.WriteJSON(Message[StartGameContent]{
+	userWaiting.writeJSON(Message[StartGameContent]{
 		Content: StartGameContent {
 			IsChoosingTurnOrder: false,
 		},
@@ -268,7 +866,7 @@ func (r *Room) headsOrTails(user *User) error {
 			MessageType: gamemanager.MessageTypeHeadsOrTails,
 			Timestamp: timestamp(),
 		}
-		err := user.Conn.WriteJSON(update)
+		err := user.writeJSON(update)
 		if err != nil {
 			return fmt.Errorf("failed to WriteJSON for update: %s", err.Error())
 		}
@@ -306,7 +904,7 @@ func (r *Room) headsOrTails(user *User) error {
 			MessageType: gamemanager.MessageTypeHeadsOrTails,
 			Timestamp: timestamp(),
 		}
-		user.Conn.WriteJSON(update)
+		user.writeJSON(update)
 	} else {
 		log.Println("Haven't handled scenario with more than 2 players")
 	}
@@ -315,19 +913,30 @@ func (r *Room) headsOrTails(user *User) error {
 }
 
 func (r *Room) sendInitialGameState(goingFirst bool) {
+	r.gameMutex.Lock()
+	defer r.gameMutex.Unlock()
+
 	p1Info, p2Info := r.Game.StartGame(goingFirst)
+	r.updateTurn()
 
-	r.ReadyPlayers[0].Conn.WriteJSON(Message[gamemanager.UpdateInfo]{
+	r.ReadyPlayers[0].writeJSON(Message[gamemanager.UpdateInfo]{
 		Content: *p1Info,
 		MessageType: gamemanager.MessageTypeGameplay,
 		Timestamp: timestamp(),
 	})
 
-	r.ReadyPlayers[1].Conn.WriteJSON(Message[gamemanager.UpdateInfo]{
+	r.ReadyPlayers[1].writeJSON(Message[gamemanager.UpdateInfo]{
 		Content: *p2Info,
 		MessageType: gamemanager.MessageTypeGameplay,
 		Timestamp: timestamp(),
 	})
+
+	r.sendToSpectators([PlayersToStartGame]*gamemanager.UpdateInfo{p1Info, p2Info})
+	r.recordForDelayed(Message[gamemanager.Snapshot]{
+		Content: *r.Game.GetOpenHandsSnapshot(),
+		MessageType: gamemanager.MessageTypeSnapshot,
+		Timestamp: timestamp(),
+	})
 }
 
 func (r *Room) startGame(user *User) error {
@@ -336,7 +945,9 @@ func (r *Room) startGame(user *User) error {
 		return fmt.Errorf("error with heads or tails: %s", err.Error())
 	}
 
-	r.wait(DESC_HEADS_OR_TAILS_CHOSEN)
+	if !r.wait(DESC_HEADS_OR_TAILS_CHOSEN) {
+		return errOpponentLeft
+	}
 
 	if (r.ExpectingCoinFlip == CoinFlipUnset) {
 		return errors.New("coin flip isn't set by evaluation time")
@@ -346,11 +957,18 @@ func (r *Room) startGame(user *User) error {
 	// or rather let the server call initiated by player 1 
 	// execute the below code
 	if r.PlayerToGamePlayerID[user] != 0 { 
-		r.wait(DESC_INITIAL_STATE_TO_CLIENT)
+		if !r.wait(DESC_INITIAL_STATE_TO_CLIENT) {
+			return errOpponentLeft
+		}
 		return nil
 	} 
 
 
+	// both players' entropy has been read by now
+	if r.fair != nil {
+		r.SetSeed(r.fair.seed())
+	}
+
 	goingFirst, err := r.askTurnOrder()
 	if err != nil {
 		return err
@@ -358,45 +976,54 @@ func (r *Room) startGame(user *User) error {
 
 	r.sendInitialGameState(goingFirst)
 
-	r.wait(DESC_INITIAL_STATE_TO_CLIENT)
+	if !r.wait(DESC_INITIAL_STATE_TO_CLIENT) {
+		return errOpponentLeft
+	}
 
 	return nil
 }
 
 func (r *Room) playerLoop(user *User) {
+	// if the player reconnects, this loop ends once 
+	// the old connection is closed
+	conn := user.getConn()
 	for {
-		action, err := r.readForActions(user.Conn)
+		msg, err := r.readForActions(conn)
 
-		if (err != nil) {
+		var gameError *gamemanager.GameError
+		if errors.As(err, &gameError) {
+			err = r.sendError(user, gameError)
+			if err != nil {
+				log.Println("Stopped sending to user, endcode: ", err)
+				break
+			}
+			continue
+		} else if (err != nil) {
 			log.Println("Stopped reading from user, endcode: ", err)
 			break
 		}
 
-    info, oppInfo, err := r.processAction(user, &action)
-    if err != nil {
-			log.Println("Error processing game action: ", err)
-			break
-    }
-
-    err = r.sendUpdateInfo(user, info)
-    if err != nil {
-			log.Println("Stopped sending to user, endcode: ", err)
-			break
-    }
-
-    id := r.PlayerToGamePlayerID[user]
-    err = r.sendUpdateInfo(r.ReadyPlayers[1-id], oppInfo)
-    if err != nil {
-			log.Println("Stopped sending to user, endcode: ", err)
+		if msg.MessageType == gamemanager.MessageTypeSnapshot {
+			err = r.handleSnapshotRequest(user)
+		} else {
+			err = r.handleAction(user, &msg.Content)
+		}
+		if err != nil {
+			log.Println("Stopped handling actions from user, endcode: ", err)
 			break
-    }
-
+		}
 	}
 }
 
-func (r *Room) wait(newDescription RoomDescription) {
+// Waits for all players to get to this point, returning 
+// false if a player left and the game can't go on
+func (r *Room) wait(newDescription RoomDescription) bool {
 	fmt.Println("starting wait for:", newDescription)
-	r.barrier.Wait()
-	r.RoomDescription = newDescription
+	if !r.barrier.Wait() {
+		fmt.Println("Stopped wait for:", newDescription)
+		return false
+	}
+	r.setDescription(newDescription)
 	fmt.Println("End wait for:", newDescription)
+	return true
 }
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"sort"
+	"time"
+)
+
+// What the room listings show about a room
+type RoomSummary struct {
+	ID           RoomID          `json:"id"`
+	Description  RoomDescription `json:"description"`
+	Players      int             `json:"players"`
+	Spectators   int             `json:"spectators"`
+	// 0 until the game starts
+	TurnNumber   uint            `json:"turnNumber"`
+	// The index of the player whose turn it is, or -1 
+	// until the game starts
+	ActivePlayer int             `json:"activePlayer"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	// The version of the cards the room's game is played with
+	CardVersion  uint            `json:"cardVersion"`
+	Users        []UserSummary   `json:"users"`
+}
+
+type UserSummary struct {
+	IsSpectator bool `json:"isSpectator"`
+	IsActive    bool `json:"isActive"`
+}
+
+// Whose turn it is in a room's game, as the listings show it
+type turnSummary struct {
+	number       uint
+	activePlayer int
+}
+
+// Copies the turn out of the game for the listings. 
+// The game mutex has to be held while calling this
+func (r *Room) updateTurn() {
+	turn := turnSummary{number: r.Game.TurnNumber, activePlayer: -1}
+	if r.hasInitialState() {
+		turn.activePlayer = int(r.Game.ActivePlayer)
+	}
+
+	r.turnMutex.Lock()
+	defer r.turnMutex.Unlock()
+	r.turn = turn
+}
+
+func (r *Room) summary() RoomSummary {
+	r.turnMutex.Lock()
+	turn := r.turn
+	r.turnMutex.Unlock()
+
+	r.connectionsMutex.Lock()
+	defer r.connectionsMutex.Unlock()
+
+	summary := RoomSummary{
+		ID: r.ID,
+		Description: r.Description(),
+		TurnNumber: turn.number,
+		ActivePlayer: turn.activePlayer,
+		CreatedAt: r.CreatedAt,
+		CardVersion: r.Game.CardHandler.Version,
+		Users: make([]UserSummary, 0, len(r.Connections)),
+	}
+	for user, isActive := range r.Connections {
+		if isActive && user.IsSpectator {
+			summary.Spectators++
+		} else if isActive {
+			summary.Players++
+		}
+		summary.Users = append(summary.Users, UserSummary{
+			IsSpectator: user.IsSpectator,
+			IsActive: isActive,
+		})
+	}
+	return summary
+}
+
+// Returns a summary of every room that isn't private, oldest
+// first. Private rooms, including the ones made by matchmaking,
+// are only for whoever was given their ID
+func (s *Server) roomSummaries() []RoomSummary {
+	rooms := s.Rooms.List()
+	summaries := make([]RoomSummary, 0, len(rooms))
+	for _, room := range rooms {
+		if room.Options.Private {
+			continue
+		}
+		summaries = append(summaries, room.summary())
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].CreatedAt.Equal(summaries[j].CreatedAt) {
+			return summaries[i].ID < summaries[j].ID
+		}
+		return summaries[i].CreatedAt.Before(summaries[j].CreatedAt)
+	})
+	return summaries
+}
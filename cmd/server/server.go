@@ -1,28 +1,86 @@
 package server
 
 import (
+	"embed"
 	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
-	"path/filepath"
+	"sync"
 
 	"github.com/Zarone/CardGameServer/cmd/gamemanager"
+	"github.com/gorilla/websocket"
 )
 
 type Server struct {
-	Rooms       map[uint8]*Room
-	settings    ServerSettings
-  cardHandler *gamemanager.CardHandler
+	Rooms        *RoomRegistry
+	matchmaker   *Matchmaker
+	settings     ServerSettings
+	cardInfoPath string
+	// The cards new rooms are made with, replaced by ReloadCards
+	cardHandler  *gamemanager.CardHandler
+	cardsMutex   sync.RWMutex
 }
 
-// Makes a new server
+// Makes a new server, exiting if its cards can't be loaded
 func MakeServer(settings *ServerSettings, cardInfoPath string) *Server {
+	s, err := NewServer(settings, cardInfoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// Makes a new server, or returns an error if its cards can't 
+// be loaded, or in strict mode, if any of them are invalid
+func NewServer(settings *ServerSettings, cardInfoPath string) (*Server, error) {
+	cardHandler, problems, err := loadCards(cardInfoPath, settings.StrictCards, true)
+	if err != nil {
+		return nil, err
+	}
+	logCardProblems(cardInfoPath, problems)
+	cardHandler.Version = 1
+
+	idleTimeout := settings.IdleRoomTimeout
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleRoomTimeout
+	}
+
+	ratingRange := settings.MatchmakingRatingRange
+	if ratingRange == 0 {
+		ratingRange = DefaultMatchmakingRatingRange
+	}
+
 	return &Server{
-		Rooms: make(map[uint8]*Room),
+		Rooms: NewRoomRegistry(idleTimeout),
+		matchmaker: NewMatchmaker(ratingRange),
 		settings: *settings,
-    cardHandler: gamemanager.SetupFromDirectory(cardInfoPath),
+		cardInfoPath: cardInfoPath,
+		cardHandler: cardHandler,
+	}, nil
+}
+
+// Loads the cards in the directory, returning any problems with
+// them alongside the cards, or failing on them in strict mode.
+// Files that can't be loaded at all are only problems if partial
+// is set, and otherwise fail the load too
+func loadCards(path string, strict bool, partial bool) (*gamemanager.CardHandler, []error, error) {
+	cardHandler, err := gamemanager.LoadFromDirectory(path)
+	if cardHandler == nil || (err != nil && !partial) {
+		return nil, nil, fmt.Errorf("error loading cards from %s: %w", path, err)
+	}
+
+	err = errors.Join(err, cardHandler.Validate())
+	if err != nil && strict {
+		return nil, nil, fmt.Errorf("invalid cards in %s:\n%w", path, err)
+	}
+	return cardHandler, gamemanager.SplitErrors(err), nil
+}
+
+func logCardProblems(path string, problems []error) {
+	if len(problems) > 0 {
+		log.Printf("Problems with cards in %s:\n%s", path, errors.Join(problems...))
 	}
 }
 
@@ -30,8 +88,8 @@ func (s *Server) String() string {
 	str := "[Server: \n" +
 		"    " + s.settings.toString()
 	
-	for i, room := range s.Rooms {
-		str += fmt.Sprintf("\n    Room %d: %s", i, room)
+	for _, room := range s.Rooms.List() {
+		str += fmt.Sprintf("\n    Room %s: %s", room.ID, room)
 	}
 
 	str += "\n]"
@@ -41,30 +99,47 @@ func (s *Server) String() string {
 
 // AddToRoom attempts to add connection to the room specified by the request
 func (s *Server) AddToRoom(req *http.Request, user *User) (*Room, error) {
-	roomNum := requestToRoomNumber(req)
-
-	if (s.Rooms[roomNum] == nil) { 
-    s.Rooms[roomNum] = MakeRoom(roomNum, s.cardHandler) 
-  }
+	roomID := requestToRoomID(req)
 
-	thisRoom := s.Rooms[roomNum]
+	thisRoom, ok := s.Rooms.Get(roomID)
+	if !ok {
+		return nil, &gamemanager.GameError{
+			Code: gamemanager.ErrorCodeUnknownRoom,
+			Message: fmt.Sprintf("there's no room %q, rooms have to be created first", roomID),
+		}
+	}
 
-	if thisRoom.GetPlayersInRoom() >= PlayersToStartGame {
-		errorString := fmt.Sprintf("Can't join. Too many players in room %d\n", roomNum)
+	if !user.IsSpectator && thisRoom.GetPlayersInRoom() >= PlayersToStartGame {
+		errorString := fmt.Sprintf("Can't join. Too many players in room %s\n", roomID)
 		return thisRoom, errors.New(errorString)
 	} else if !user.IsSpectator {
 		err := thisRoom.InitPlayer(user)
 		if (err != nil) { 
-			log.Println(err)
-			return thisRoom, nil
+			return thisRoom, err
 		}
 	}
 
-	s.Rooms[roomNum].Connections[user] = true
+	thisRoom.addConnection(user)
 
 	return thisRoom, nil
 }
 
+// Makes a room with the server's settings and the given options
+func (s *Server) makeRoom(id RoomID, options RoomOptions) (*Room, error) {
+	room := MakeRoom(id, s.currentCards())
+	room.Options = options
+	if s.settings.Seed != 0 {
+		room.SetSeed(s.settings.Seed)
+	}
+	if s.settings.ProvablyFair || options.ProvablyFair {
+		room.Options.ProvablyFair = true
+		if err := room.makeFair(); err != nil {
+			return nil, fmt.Errorf("error making room fair: %s", err)
+		}
+	}
+	return room, nil
+}
+
 func (s *Server) RemoveUserFromRoom(user *User, room *Room) error {
 	return room.RemoveFromRoom(user)
 }
@@ -76,59 +151,150 @@ func (s *Server) HandleWS(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if token := req.URL.Query().Get("reconnect"); token != "" {
+		s.handleReconnect(ws, token)
+		return
+	}
+
 	user := User{
 		Conn: ws,
 		IsSpectator: req.URL.Query().Get("spectator") == "true",
 	}
+	if user.IsSpectator && req.URL.Query().Get("delayed") == "true" {
+		user.embargo = s.makeEmbargo()
+	} else if user.IsSpectator {
+		user.followedPlayer, user.isFollowing = requestToFollowedPlayer(req)
+		user.presentedToken = req.URL.Query().Get("token")
+	}
 
 	room, err := s.AddToRoom(req, &user)
 	if err != nil {
 		log.Printf("Error adding to room: %s", err)
+		if gamemanager.IsGameError(err) {
+			user.writeJSON(Message[gamemanager.GameError]{
+				Timestamp: timestamp(),
+				Content: *gamemanager.ToGameError(err),
+				MessageType: gamemanager.MessageTypeError,
+			})
+		}
+		ws.Close()
 		return
 	}
 
-	defer s.RemoveUserFromRoom(&user, room)
+	s.runInRoom(ws, room, &user)
+}
+
+// Takes a user who has joined the room through the setup of the
+// game, and then plays or watches it until they leave
+func (s *Server) runInRoom(ws *websocket.Conn, room *Room, user *User) {
+	defer room.disconnect(user, ws)
 
 	log.Printf("Client [%p] Connected\n", ws)
-	err = ws.WriteMessage(1, []byte("Hi Client!"))
+	err := ws.WriteMessage(1, []byte("Hi Client!"))
 	if err != nil {
 		log.Printf("Error writing on client connection: %s", err)
 		return
 	}
 
-	params, err := room.readSetupParams(&user)
+	if err := room.sendCommitment(user); err != nil {
+		log.Printf("Error writing on client connection: %s", err)
+		return
+	}
+
+	params, err := room.readSetupParams(user)
 	if err != nil {
 		log.Printf("Error reading setup parameters %s", err)
 		return
 	}
 
 	if user.IsSpectator {
-		room.spectatorLoop(&user)
+		room.spectatorLoop(user)
 	} else {
-    room.initGameData(&user, params.Content.Deck)
+		if err := room.initGameData(user, params.Content.Deck); err == errOpponentLeft {
+			room.sendError(user, errOpponentLeft)
+			return
+		} else if err != nil {
+			log.Printf("Error setting up deck: %s", err)
+			room.sendError(user, gamemanager.ToGameError(err))
+			return
+		}
 
 		// Wait for all players to finish initialization
-		room.wait(DESC_FINISHED_INITIALIZATION)
+		if !room.wait(DESC_FINISHED_INITIALIZATION) {
+			room.sendError(user, errOpponentLeft)
+			return
+		}
 
-		var setupResponseMessage Message[SetupResponse] = room.getInitData(&user)
+		var setupResponseMessage Message[SetupResponse] = room.getInitData(user)
 
-		if err := user.Conn.WriteJSON(setupResponseMessage); err != nil {
+		if err := user.writeJSON(setupResponseMessage); err != nil {
 			fmt.Println(fmt.Errorf("error writing message: %s", err))
 			return 
 		}
 
-		err := room.startGame(&user)
-		if err != nil {
+		err := room.startGame(user)
+		if err == errOpponentLeft {
+			room.sendError(user, errOpponentLeft)
+			return
+		} else if err != nil {
 			log.Printf("Error starting game: %s", err)
 			return
 		}
 
-		room.playerLoop(&user)
+		room.playerLoop(user)
+	}
+}
+
+// Returns the number of players in the matchmaking queue
+func (s *Server) PlayersWaitingForMatch() int {
+	return s.matchmaker.Waiting()
+}
+
+// Rebinds the player holding the token to the new connection, 
+// and carries on their game from where they left it
+func (s *Server) handleReconnect(ws *websocket.Conn, token string) {
+	room, user := s.findPlayerByToken(token)
+	if user == nil {
+		log.Printf("Client [%p] tried to reconnect with unknown token\n", ws)
+		ws.WriteJSON(Message[gamemanager.GameError]{
+			Content: gamemanager.GameError{
+				Code: gamemanager.ErrorCodeUnknownToken,
+				Message: "no game is waiting on this reconnect token",
+			},
+			MessageType: gamemanager.MessageTypeError,
+			Timestamp: timestamp(),
+		})
+		ws.Close()
+		return
+	}
+
+	defer room.disconnect(user, ws)
+
+	log.Printf("Client [%p] Reconnected\n", ws)
+	if err := room.Reconnect(user, ws); err != nil {
+		log.Printf("Error reconnecting: %s", err)
+		return
 	}
+
+	room.playerLoop(user)
 }
 
+// Returns the room and player with the given reconnect token,
+// or a nil player if there isn't one
+func (s *Server) findPlayerByToken(token string) (*Room, *User) {
+	for _, room := range s.Rooms.List() {
+		if user := room.findPlayerByToken(token); user != nil {
+			return room, user
+		}
+	}
+	return nil, nil
+}
+
+//go:embed templates
+var templates embed.FS
+
 func (s *Server) HandleRoomsPage(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles(filepath.Join("cmd", "server", "templates", "rooms.html"))
+	tmpl, err := template.ParseFS(templates, "templates/rooms.html")
 	if err != nil {
 		http.Error(w, "Error loading template", http.StatusInternalServerError)
 		return
@@ -136,47 +302,21 @@ func (s *Server) HandleRoomsPage(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, nil)
 }
 
+// Responds with the HTML listing of the rooms, for the rooms page
 func (s *Server) HandleRoomsAPI(w http.ResponseWriter, r *http.Request) {
-	// Generate HTML for the rooms
-	var html string
-	for _, room := range s.Rooms {
-		html += fmt.Sprintf(`
-			<div class="room">
-				<h2>Room %d (%s)</h2>
-				<ul class="user-list">`, 
-			room.RoomNumber, 
-			room.RoomDescription,
-		)
-		
-		for player, isActive := range room.Connections {
-			status := "Player"
-
-			if player.IsSpectator {
-				status = "Spectator"
-			}
-
-			if isActive {
-				status += " (Active)"
-			} else {
-				status += " (Not Active)"
-			}
-
-			spectatorText := ""
-			
-			if player.IsSpectator { spectatorText = "spectator" }
-
-			html += fmt.Sprintf(`
-				<li class="%s">%s</li>`, 
-				spectatorText,
-				status,
-			)
-		}
-		
-		html += `
-				</ul>
-			</div>`
+	tmpl, err := template.ParseFS(templates, "templates/roomList.html")
+	if err != nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		return
 	}
-	
+
 	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(html))
+	if err := tmpl.Execute(w, s.roomSummaries()); err != nil {
+		log.Printf("Error rendering rooms: %s", err)
+	}
+}
+
+// Responds with the same listing of the rooms as JSON
+func (s *Server) HandleRoomsJSON(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, s.roomSummaries())
 }
@@ -1,8 +1,56 @@
 package server
 
+import (
+  "fmt"
+  "time"
+)
+
+// Used for delayed spectators when neither delay is set
+const DefaultSpectatorDelay = 30 * time.Second
+
 type ServerSettings struct {
+  // How far behind the game delayed spectators are kept, in 
+  // time and in actions, whichever holds them back longer. 
+  // Delayed spectators can see both hands, so this is the 
+  // server's to set rather than theirs
+  SpectatorDelay        time.Duration
+  SpectatorDelayActions int
+
+  // Seeds every room's coin flip and shuffles, so games can be
+  // reproduced. Rooms are seeded randomly if it's 0
+  Seed                  int64
+
+  // Seeds rooms from a seed the server commits to, mixed with
+  // entropy from the players, instead of from Seed
+  ProvablyFair          bool
+
+  // How long a room can go without anyone in it before it's
+  // removed. Uses DefaultIdleRoomTimeout if it's 0
+  IdleRoomTimeout       time.Duration
+
+  // How far apart the ratings of matched players can be. Uses
+  // DefaultMatchmakingRatingRange if it's 0
+  MatchmakingRatingRange int
+
+  // Refuses to start the server if any card fails validation,
+  // rather than only logging the problems
+  StrictCards           bool
+
+  // Presented as a bearer token to use the admin endpoints,
+  // which are disabled if it's empty
+  AdminToken            string
 }
 
 func (settings *ServerSettings) toString() string {
-  return "[ServerSettings: ]"
+  return fmt.Sprintf(
+    "[ServerSettings: SpectatorDelay: %s, SpectatorDelayActions: %d, Seed: %d, ProvablyFair: %t, IdleRoomTimeout: %s, MatchmakingRatingRange: %d, StrictCards: %t, AdminToken set: %t]", 
+    settings.SpectatorDelay, 
+    settings.SpectatorDelayActions,
+    settings.Seed,
+    settings.ProvablyFair,
+    settings.IdleRoomTimeout,
+    settings.MatchmakingRatingRange,
+    settings.StrictCards,
+    settings.AdminToken != "",
+  )
 }
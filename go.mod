@@ -2,4 +2,4 @@ module github.com/Zarone/CardGameServer
 
 go 1.23.3
 
-require github.com/gorilla/websocket v1.5.3 // indirect
+require github.com/gorilla/websocket v1.5.3
@@ -2,7 +2,11 @@ package gamemanager_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Zarone/CardGameServer/cmd/gamemanager"
@@ -62,7 +66,7 @@ func TestGameStartGame(t *testing.T) {
 	game.AddPlayer()
 	
 	// Set up players with some cards
-	deck := []uint{1, 1, 1, 1, 1, 2, 2, 2, 2, 2}
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 2, 2, 2, 2, 2)
 	game.SetupPlayer(0, deck)
 	game.SetupPlayer(1, deck)
 	
@@ -139,7 +143,7 @@ func TestGameStartGameWithFewCards(t *testing.T) {
 	game.AddPlayer()
 	
 	// Set up players with fewer than 7 cards
-	deck := []uint{1, 2, 3}
+	deck := gamemanager.DeckFromSet("set1", 1, 2, 3)
 	game.SetupPlayer(0, deck)
 	game.SetupPlayer(1, deck)
 	
@@ -269,7 +273,7 @@ func TestPlayUltraBall(t *testing.T) {
 	game.AddPlayer()
 	game.AddPlayer()
 	
-	deck := []uint{0, 0, 1}
+	deck := gamemanager.DeckFromSet("set1", 0, 0, 1)
 	game.SetupPlayer(0, deck)
 	game.SetupPlayer(1, deck)
 	
@@ -386,3 +390,1613 @@ func TestPlayUltraBall(t *testing.T) {
   }
 
 }
+
+func TestPlayShuffle(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Shuffler",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "THEN", 
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "SHUFFLE",
+            "pile": "DECK"
+          }
+        ]
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	shufflerID := game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID
+
+	deckBefore := make(map[uint]bool)
+	for _, card := range game.Players[0].PlayerPiles[gamemanager.DECK_PILE].Cards {
+		deckBefore[card.GameID] = true
+	}
+
+	info, oppInfo, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{shufflerID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	if len(info.ShuffledPiles) != 1 || info.ShuffledPiles[0] != gamemanager.DECK_PILE {
+		t.Errorf("Expected deck to be reported as shuffled, got %v", info.ShuffledPiles)
+	}
+
+	if len(oppInfo.ShuffledPiles) != 1 || oppInfo.ShuffledPiles[0] != gamemanager.OPP_DECK_PILE {
+		t.Errorf("Expected opponent to see their opponent's deck shuffled, got %v", oppInfo.ShuffledPiles)
+	}
+
+	if len(info.Movements) != 1 || info.Movements[0].To != gamemanager.DISCARD_PILE {
+		t.Errorf("Expected only the shuffler to move, got %v", info.Movements)
+	}
+
+	deckAfter := game.Players[0].PlayerPiles[gamemanager.DECK_PILE].Cards
+	if len(deckAfter) != len(deckBefore) {
+		t.Fatalf("Deck size changed from %d to %d", len(deckBefore), len(deckAfter))
+	}
+	for _, card := range deckAfter {
+		if !deckBefore[card.GameID] {
+			t.Errorf("Card with GameID %d appeared in deck after shuffling", card.GameID)
+		}
+	}
+}
+
+func TestPlayOrEffect(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Chooser",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "THEN", 
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "OR",
+            "args": [
+              {
+                "kind": "SHUFFLE",
+                "label": "Shuffle your deck",
+                "pile": "DECK"
+              },
+              {
+                "kind": "MOVE",
+                "label": "Discard 9 cards",
+                "target": {
+                  "kind": "TARGET",
+                  "targetType": "SELECT",
+                  "filter": {
+                    "kind": "JUST",
+                    "pile": "HAND",
+                    "count": { "atLeast": 9, "atMost": 9 }
+                  }
+                },
+                "to": "DISCARD"
+              },
+              {
+                "kind": "MOVE",
+                "label": "Discard a card",
+                "target": {
+                  "kind": "TARGET",
+                  "targetType": "SELECT",
+                  "filter": {
+                    "kind": "JUST",
+                    "pile": "HAND",
+                    "count": { "atLeast": 1, "atMost": 1 }
+                  }
+                },
+                "to": "DISCARD"
+              }
+            ]
+          }
+        ]
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	hand := game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards
+	chooserID := hand[0].GameID
+
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{chooserID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	if info.Phase != gamemanager.PHASE_SELECTING_OPTION {
+		t.Fatalf("Expected to be choosing an option, got phase %v", info.Phase)
+	}
+
+	// discarding 9 cards isn't possible with 6 cards in hand
+	if len(info.Options) != 2 || info.Options[0].Index != 0 || info.Options[1].Index != 2 {
+		t.Fatalf("Expected options 0 and 2, got %v", info.Options)
+	}
+	if info.Options[1].Label != "Discard a card" {
+		t.Errorf("Expected option label to be sent, got %q", info.Options[1].Label)
+	}
+
+	_, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeChooseOption,
+		Option: 1,
+	})
+	if err == nil {
+		t.Error("Expected choosing an unavailable option to fail")
+	}
+
+	info, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeChooseOption,
+		Option: 2,
+	})
+	if err != nil {
+		t.Fatalf("Error choosing option: %v", err)
+	}
+
+	if info.Phase != gamemanager.PHASE_SELECTING_CARDS {
+		t.Fatalf("Expected to be selecting cards, got phase %v", info.Phase)
+	}
+
+	toDiscard := game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID
+	info, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeFinishSelection,
+		SelectedCards: []uint{toDiscard},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error finishing selection: %v", err)
+	}
+
+	if info.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Not set back to my turn")
+	}
+
+	if len(info.Movements) != 1 || info.Movements[0].GameID != toDiscard || info.Movements[0].To != gamemanager.DISCARD_PILE {
+		t.Errorf("Expected selected card to be discarded, got %v", info.Movements)
+	}
+
+	if len(game.Players[0].PlayerPiles[gamemanager.DISCARD_PILE].Cards) != 2 {
+		t.Errorf("Expected 2 cards in discard, got %d", len(game.Players[0].PlayerPiles[gamemanager.DISCARD_PILE].Cards))
+	}
+}
+
+func TestResumeAfterOrEffect(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Chooser",
+      "imageSrc": "card2",
+      "effect": {
+        "kind": "THEN",
+        "args": [
+          {
+            "kind": "OR",
+            "args": [
+              { "kind": "SHUFFLE", "label": "Shuffle your deck", "pile": "DECK" },
+              { "kind": "SHUFFLE", "label": "Shuffle your hand", "pile": "HAND" }
+            ]
+          },
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          }
+        ]
+      }
+    },
+    {
+      "name": "Greedy",
+      "imageSrc": "card3",
+      "effect": {
+        "kind": "OR",
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "SELECT",
+              "filter": {
+                "kind": "JUST",
+                "pile": "HAND",
+                "count": { "atLeast": 9, "atMost": 9 }
+              }
+            },
+            "to": "DISCARD"
+          }
+        ]
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 2, 0, 0, 0, 0, 0)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	startInfo, _ := game.StartGame(true)
+
+	// none of Greedy's branches can resolve with 7 cards in hand
+	var chooserID uint
+	playable := make(map[uint]bool)
+	for _, gameID := range startInfo.SelectableCards {
+		playable[gameID] = true
+	}
+	for _, card := range game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards {
+		if card.ID == 1 {
+			chooserID = card.GameID
+		}
+		if playable[card.GameID] != (card.ID != 2) {
+			t.Errorf("Expected card %d to be playable: %t", card.ID, card.ID != 2)
+		}
+	}
+
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{chooserID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	// the card played is still the one moved after the choice
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeChooseOption,
+		Option: 0,
+	})
+	if err != nil {
+		t.Fatalf("Error choosing option: %v", err)
+	}
+	if len(info.Movements) != 1 || info.Movements[0].GameID != chooserID || info.Movements[0].To != gamemanager.DISCARD_PILE {
+		t.Errorf("Expected Chooser to be discarded, got %v", info.Movements)
+	}
+	if game.IsOver() {
+		t.Errorf("Expected the game to go on, got %v", game.Result)
+	}
+}
+
+func TestCompositeFilter(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1",
+      "cardType": "BASIC_CHARACTER"
+    },
+    {
+      "name": "card 2",
+      "imageSrc": "card2",
+      "cardType": "EVENT"
+    },
+    {
+      "name": "Searcher",
+      "imageSrc": "card3",
+      "effect": { 
+        "kind": "THEN", 
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "SELECT",
+              "filter": {
+                "kind": "OR",
+                "count": { "atLeast": 1, "atMost": 2 },
+                "args": [
+                  { "kind": "JUST", "pile": "DECK", "type": "BASIC_CHARACTER" },
+                  { 
+                    "kind": "AND", 
+                    "args": [
+                      { "kind": "JUST", "pile": "DISCARD" },
+                      { "kind": "JUST", "pile": "DISCARD", "type": "EVENT" }
+                    ]
+                  }
+                ]
+              }
+            },
+            "to": "HAND"
+          }
+        ]
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 2, 2, 2, 2, 2, 2, 2, 0, 0, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	// leave only searchers in hand and a known deck
+	player := &game.Players[0]
+	hand := player.PlayerPiles[gamemanager.HAND_PILE]
+	deckPile := player.PlayerPiles[gamemanager.DECK_PILE]
+	all := append(append(make([]gamemanager.Card, 0), hand.Cards...), deckPile.Cards...)
+	hand.Cards = hand.Cards[:0]
+	deckPile.Cards = deckPile.Cards[:0]
+	for _, card := range all {
+		if card.ID == 2 {
+			hand.Cards = append(hand.Cards, card)
+			player.FindID[card.GameID] = hand
+		} else {
+			deckPile.Cards = append(deckPile.Cards, card)
+			player.FindID[card.GameID] = deckPile
+		}
+	}
+
+	expected := make(map[uint]bool)
+	for _, card := range deckPile.Cards {
+		if card.ID == 0 {
+			expected[card.GameID] = true
+		}
+	}
+
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{hand.Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	// the discard only holds the searcher, which isn't an EVENT
+	if len(info.SelectableCards) != len(expected) {
+		t.Fatalf("Expected %d selectable cards, got %v", len(expected), info.SelectableCards)
+	}
+	for _, gameID := range info.SelectableCards {
+		if !expected[gameID] {
+			t.Errorf("Card with GameID %d should not be selectable", gameID)
+		}
+	}
+
+	if info.SelectionRestrictions.AtLeast != 1 || info.SelectionRestrictions.AtMost != 2 {
+		t.Errorf("Expected count of the combined filter, got %v", info.SelectionRestrictions)
+	}
+}
+
+func TestTopFilter(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1",
+      "cardType": "BASIC_CHARACTER"
+    },
+    {
+      "name": "Looker",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "MOVE",
+        "target": {
+          "kind": "TARGET",
+          "targetType": "SELECT",
+          "filter": {
+            "kind": "JUST",
+            "pile": "DECK",
+            "type": "BASIC_CHARACTER",
+            "top": 3,
+            "count": { "atLeast": 0, "atMost": 1 }
+          }
+        },
+        "to": "HAND"
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	var lookerID uint
+	found := false
+	for _, card := range game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards {
+		if card.ID == 1 {
+			lookerID = card.GameID
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Expected a looker in hand")
+	}
+
+	deckCards := game.Players[0].PlayerPiles[gamemanager.DECK_PILE].Cards
+	topCards := make(map[uint]uint)
+	for _, card := range deckCards[len(deckCards)-3:] {
+		topCards[card.GameID] = card.ID
+	}
+
+	info, oppInfo, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{lookerID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	if len(info.OpenViewCards) != 3 || len(info.RevealedCards) != 3 {
+		t.Fatalf("Expected top 3 cards to be revealed, got %v and %v", info.OpenViewCards, info.RevealedCards)
+	}
+	for _, reveal := range info.RevealedCards {
+		cardID, ok := topCards[reveal.GameID]
+		if !ok || cardID != reveal.CardID {
+			t.Errorf("Revealed card %v is not one of the top 3 cards", reveal)
+		}
+	}
+
+	for _, gameID := range info.SelectableCards {
+		cardID, ok := topCards[gameID]
+		if !ok || cardID != 0 {
+			t.Errorf("Card with GameID %d should not be selectable", gameID)
+		}
+	}
+
+	if len(oppInfo.OpenViewCards) != 0 || len(oppInfo.RevealedCards) != 0 {
+		t.Error("Revealed cards were shown to the opponent")
+	}
+
+	info, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeFinishSelection,
+		SelectedCards: []uint{},
+		From: gamemanager.DECK_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error finishing selection: %v", err)
+	}
+
+	if len(info.OpenViewCards) != 0 || len(info.RevealedCards) != 0 {
+		t.Error("Revealed cards were not hidden again after the effect resolved")
+	}
+}
+
+func TestUnresolvableEffect(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromDirectory(cardInfoPath))
+	game.AddPlayer()
+	game.AddPlayer()
+
+	// only Ultra Balls, all drawn, so there's nothing to search the deck for
+	deck := gamemanager.DeckFromSet("set1", 5, 5, 5, 5, 5, 5, 5)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	info, _ := game.StartGame(true)
+
+	if len(game.Players[0].PlayerPiles[gamemanager.DECK_PILE].Cards) != 0 {
+		t.Fatalf("Expected the deck to be empty")
+	}
+	if len(info.SelectableCards) != 0 {
+		t.Errorf("Expected Ultra Ball not to be playable, got %v", info.SelectableCards)
+	}
+
+	ultraBallID := game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{ultraBallID},
+		From: gamemanager.HAND_PILE,
+	})
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeCantPlay {
+		t.Fatalf("Expected Ultra Ball to be refused, got %v", err)
+	}
+	if game.CardActionStack != nil || len(game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards) != 7 {
+		t.Errorf("Expected nothing to have happened")
+	}
+
+	// so the player is free to end their turn
+	if _, _, err := game.ProcessAction(0, &gamemanager.Action{ActionType: gamemanager.ActionTypeEndTurn}); err != nil {
+		t.Errorf("Expected to be able to end the turn, got %v", err)
+	}
+}
+
+func TestInvalidSelection(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Discarder",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "MOVE",
+        "target": {
+          "kind": "TARGET",
+          "targetType": "SELECT",
+          "filter": {
+            "kind": "JUST",
+            "pile": "HAND",
+            "count": { "atLeast": 2, "atMost": 2 }
+          }
+        },
+        "to": "DISCARD"
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	hand := game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards
+	oppHand := game.Players[1].PlayerPiles[gamemanager.HAND_PILE].Cards
+
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeFinishSelection,
+		SelectedCards: []uint{hand[0].GameID, hand[1].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeNothingPending {
+		t.Errorf("Expected finishing a selection with nothing pending to fail, got %v", err)
+	}
+
+	_, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{hand[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	invalidSelections := map[string][]uint{
+		"too few cards": {hand[1].GameID},
+		"too many cards": {hand[1].GameID, hand[2].GameID, hand[3].GameID},
+		"duplicate cards": {hand[1].GameID, hand[1].GameID},
+		"opponent's cards": {hand[1].GameID, oppHand[0].GameID},
+		"unknown cards": {hand[1].GameID, 1000},
+	}
+
+	for name, selection := range invalidSelections {
+		_, _, err := game.ProcessAction(0, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeFinishSelection,
+			SelectedCards: selection,
+			From: gamemanager.HAND_PILE,
+		})
+		var gameError *gamemanager.GameError
+		if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeInvalidSelection {
+			t.Errorf("Expected selecting %s to be rejected as an invalid selection, got %v", name, err)
+		}
+	}
+
+	_, _, err = game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{hand[1].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeEffectPending {
+		t.Errorf("Expected playing a card during a selection to be rejected, got %v", err)
+	}
+
+	if len(game.Players[0].PlayerPiles[gamemanager.DISCARD_PILE].Cards) != 0 || 
+	len(game.Players[1].PlayerPiles[gamemanager.DISCARD_PILE].Cards) != 0 {
+		t.Error("Invalid selection moved cards")
+	}
+
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeFinishSelection,
+		SelectedCards: []uint{hand[1].GameID, hand[2].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Expected selection to still be pending, got %v", err)
+	}
+
+	if len(info.Movements) != 2 {
+		t.Errorf("Expected 2 cards to be discarded, got %v", info.Movements)
+	}
+}
+
+func TestTargetAll(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Hand Discarder",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "THEN", 
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "ALL",
+              "filter": { "kind": "JUST", "pile": "HAND" }
+            },
+            "to": "DISCARD"
+          }
+        ]
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	hand := game.Players[0].PlayerPiles[gamemanager.HAND_PILE]
+
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{hand.Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	if info.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Expected no selection to be needed, got phase %v", info.Phase)
+	}
+
+	if len(info.Movements) != 7 {
+		t.Errorf("Expected whole hand to be discarded, got %v", info.Movements)
+	}
+
+	if len(hand.Cards) != 0 || len(game.Players[0].PlayerPiles[gamemanager.DISCARD_PILE].Cards) != 7 {
+		t.Error("Hand was not discarded")
+	}
+}
+
+func TestEndTurn(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromDirectory(cardInfoPath))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(false)
+
+	if !game.IsPlayersTurn(1) || game.IsPlayersTurn(0) {
+		t.Fatal("Expected player 2 to go first")
+	}
+
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeNotYourTurn {
+		t.Errorf("Expected ending the opponent's turn to be rejected, got %v", err)
+	}
+
+	info, oppInfo, err := game.ProcessAction(1, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	if err != nil {
+		t.Fatalf("Error ending turn: %v", err)
+	}
+
+	if info.Phase != gamemanager.PHASE_OPPONENTS_TURN || oppInfo.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Expected phases to swap, got %v and %v", info.Phase, oppInfo.Phase)
+	}
+
+	if !game.IsPlayersTurn(0) {
+		t.Error("Expected it to be player 1's turn")
+	}
+
+	if len(oppInfo.Movements) != 1 || 
+		oppInfo.Movements[0].From != gamemanager.DECK_PILE || 
+		oppInfo.Movements[0].To != gamemanager.HAND_PILE {
+		t.Errorf("Expected player 1 to draw a card, got %v", oppInfo.Movements)
+	}
+
+	if len(info.Movements) != 1 || 
+		info.Movements[0].To != gamemanager.OPP_HAND_PILE || 
+		info.Movements[0].CardID != 0 {
+		t.Errorf("Expected player 2 to see a hidden draw, got %v", info.Movements)
+	}
+
+	if len(game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards) != 8 {
+		t.Errorf("Expected 8 cards in hand, got %d", len(game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards))
+	}
+
+	if len(oppInfo.SelectableCards) == 0 {
+		t.Error("Expected cards to be playable at the start of the turn")
+	}
+}
+
+func TestEndTurnAfterEmptySelection(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Clearer",
+      "imageSrc": "card2",
+      "effect": {
+        "kind": "THEN",
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "ALL",
+              "filter": { "kind": "JUST", "pile": "HAND" }
+            },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "SELECT",
+              "filter": {
+                "kind": "JUST",
+                "pile": "HAND",
+                "count": { "atLeast": 1, "atMost": 1 }
+              }
+            },
+            "to": "DECK"
+          }
+        ]
+      }
+    }
+  ]`))
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	// the hand is discarded before the selection, which can't
+	// be met anymore, so it asks for as many cards as there are
+	info, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+	if info.Phase != gamemanager.PHASE_SELECTING_CARDS || info.SelectionRestrictions.AtLeast != 0 {
+		t.Fatalf("Expected to select at least 0 cards, got %v %v", info.Phase, info.SelectionRestrictions)
+	}
+
+	_, _, err = game.ProcessAction(0, &gamemanager.Action{ActionType: gamemanager.ActionTypeEndTurn})
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeEffectPending {
+		t.Errorf("Expected the selection to have to be finished first, got %v", err)
+	}
+
+	if _, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeFinishSelection,
+		SelectedCards: []uint{},
+		From: gamemanager.HAND_PILE,
+	}); err != nil {
+		t.Fatalf("Expected finishing with nothing selected to work, got %v", err)
+	}
+	if _, _, err := game.ProcessAction(0, &gamemanager.Action{ActionType: gamemanager.ActionTypeEndTurn}); err != nil {
+		t.Errorf("Expected to be able to end the turn, got %v", err)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	cards := `[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Victory",
+      "imageSrc": "card2",
+      "effect": { "kind": "WIN" }
+    },
+    {
+      "name": "Draw Two",
+      "imageSrc": "card3",
+      "effect": {
+        "kind": "MOVE",
+        "target": {
+          "kind": "TARGET",
+          "targetType": "ALL",
+          "filter": { "kind": "JUST", "pile": "DECK", "top": 2 }
+        },
+        "to": "HAND"
+      }
+    }
+  ]`
+
+	t.Run("deck out", func(t *testing.T) {
+		game := gamemanager.MakeGame(gamemanager.SetupFromString(cards))
+		game.AddPlayer()
+		game.AddPlayer()
+		game.SetupPlayer(0, gamemanager.DeckFromSet("set1", 0, 0, 0))
+		game.SetupPlayer(1, gamemanager.DeckFromSet("set1", 0, 0, 0))
+		game.StartGame(true)
+
+		info, oppInfo, err := game.ProcessAction(0, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeEndTurn,
+		})
+		if err != nil {
+			t.Fatalf("Error ending turn: %v", err)
+		}
+
+		if !game.IsOver() || game.Result.Winner != 0 || game.Result.Reason != gamemanager.EndReasonDeckOut {
+			t.Fatalf("Expected player 2 to lose by decking out, got %v", game.Result)
+		}
+
+		if info.Phase != gamemanager.PHASE_GAME_OVER || oppInfo.Phase != gamemanager.PHASE_GAME_OVER {
+			t.Errorf("Expected game over phase, got %v and %v", info.Phase, oppInfo.Phase)
+		}
+
+		_, _, err = game.ProcessAction(1, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeEndTurn,
+		})
+		var gameError *gamemanager.GameError
+		if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeGameOver {
+			t.Errorf("Expected actions after the game ended to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("concession", func(t *testing.T) {
+		game := gamemanager.MakeGame(gamemanager.SetupFromString(cards))
+		game.AddPlayer()
+		game.AddPlayer()
+		game.SetupPlayer(0, gamemanager.DeckFromSet("set1", 0, 0, 0))
+		game.SetupPlayer(1, gamemanager.DeckFromSet("set1", 0, 0, 0))
+		game.StartGame(true)
+
+		// conceding is allowed on the opponent's turn
+		_, _, err := game.ProcessAction(1, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeConcede,
+		})
+		if err != nil {
+			t.Fatalf("Error conceding: %v", err)
+		}
+
+		if !game.IsOver() || game.Result.Winner != 0 || game.Result.Reason != gamemanager.EndReasonConcession {
+			t.Errorf("Expected player 1 to win by concession, got %v", game.Result)
+		}
+	})
+
+	t.Run("card effect", func(t *testing.T) {
+		game := gamemanager.MakeGame(gamemanager.SetupFromString(cards))
+		game.AddPlayer()
+		game.AddPlayer()
+		game.SetupPlayer(0, gamemanager.DeckFromSet("set1", 1, 1, 1))
+		game.SetupPlayer(1, gamemanager.DeckFromSet("set1", 1, 1, 1))
+		game.StartGame(false)
+
+		_, _, err := game.ProcessAction(1, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeSelectCard,
+			SelectedCards: []uint{game.Players[1].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+			From: gamemanager.HAND_PILE,
+		})
+		if err != nil {
+			t.Fatalf("Error processing action: %v", err)
+		}
+
+		if !game.IsOver() || game.Result.Winner != 1 || game.Result.Reason != gamemanager.EndReasonCardEffect {
+			t.Errorf("Expected player 2 to win by card effect, got %v", game.Result)
+		}
+	})
+
+	t.Run("drawing effect", func(t *testing.T) {
+		game := gamemanager.MakeGame(gamemanager.SetupFromString(cards))
+		game.AddPlayer()
+		game.AddPlayer()
+		// one card is left in the deck after the opening hand
+		game.SetupPlayer(0, gamemanager.DeckFromSet("set1", 2, 2, 2, 2, 2, 2, 2, 2))
+		game.SetupPlayer(1, gamemanager.DeckFromSet("set1", 0, 0, 0))
+		game.StartGame(true)
+
+		info, _, err := game.ProcessAction(0, &gamemanager.Action{
+			ActionType: gamemanager.ActionTypeSelectCard,
+			SelectedCards: []uint{game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+			From: gamemanager.HAND_PILE,
+		})
+		if err != nil {
+			t.Fatalf("Error processing action: %v", err)
+		}
+
+		if !game.IsOver() || game.Result.Winner != 1 || game.Result.Reason != gamemanager.EndReasonDeckOut {
+			t.Errorf("Expected player 1 to lose by decking out, got %v", game.Result)
+		}
+		if info.Phase != gamemanager.PHASE_GAME_OVER {
+			t.Errorf("Expected game over phase, got %v", info.Phase)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Discarder",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "MOVE",
+        "target": {
+          "kind": "TARGET",
+          "targetType": "SELECT",
+          "filter": {
+            "kind": "JUST",
+            "pile": "HAND",
+            "count": { "atLeast": 1, "atMost": 2 }
+          }
+        },
+        "to": "DISCARD"
+      }
+    }
+  ]`))
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+
+	snapshot := game.GetSnapshot(0)
+	if snapshot.Phase != gamemanager.PHASE_SELECTING_CARDS {
+		t.Errorf("Expected pending selection in snapshot, got phase %d", snapshot.Phase)
+	}
+	if snapshot.SelectionRestrictions.AtLeast != 1 || snapshot.SelectionRestrictions.AtMost != 2 {
+		t.Errorf("Expected pending selection's count in snapshot, got %v", snapshot.SelectionRestrictions)
+	}
+	if len(snapshot.SelectableCards) != 7 {
+		t.Errorf("Expected hand to be selectable, got %v", snapshot.SelectableCards)
+	}
+	for _, card := range snapshot.Piles[gamemanager.HAND_PILE] {
+		if card.CardID != 1 {
+			t.Errorf("Own hand should be visible, got %v", card)
+		}
+	}
+	for _, card := range snapshot.Piles[gamemanager.DECK_PILE] {
+		if card.CardID != 0 {
+			t.Errorf("Own deck should be hidden, got %v", card)
+		}
+	}
+	// hidden piles don't give away the order of their cards
+	for _, pile := range []gamemanager.Pile{gamemanager.DECK_PILE, gamemanager.OPP_DECK_PILE, gamemanager.OPP_HAND_PILE} {
+		cards := snapshot.Piles[pile]
+		for index := 1; index < len(cards); index++ {
+			if cards[index-1].GameID > cards[index].GameID {
+				t.Errorf("Expected %s to be sorted by GameID, got %v", pile, cards)
+				break
+			}
+		}
+	}
+	for _, card := range snapshot.Piles[gamemanager.OPP_HAND_PILE] {
+		if card.CardID != 0 {
+			t.Errorf("Opponent's hand should be hidden, got %v", card)
+		}
+	}
+
+	oppSnapshot := game.GetSnapshot(1)
+	if oppSnapshot.Phase != gamemanager.PHASE_OPPONENTS_TURN || len(oppSnapshot.SelectableCards) != 0 {
+		t.Errorf("Expected opponent to be waiting, got phase %d", oppSnapshot.Phase)
+	}
+	if len(oppSnapshot.Piles[gamemanager.OPP_HAND_PILE]) != 7 || len(oppSnapshot.Piles[gamemanager.HAND_PILE]) != 7 {
+		t.Errorf("Expected both hands in opponent's snapshot, got %v", oppSnapshot.Piles)
+	}
+
+	neutral := game.GetNeutralSnapshot()
+	if neutral.Phase != gamemanager.PHASE_SPECTATING {
+		t.Errorf("Expected spectating phase, got %d", neutral.Phase)
+	}
+	for _, pile := range []gamemanager.Pile{gamemanager.HAND_PILE, gamemanager.OPP_HAND_PILE} {
+		if len(neutral.Piles[pile]) != 7 {
+			t.Errorf("Expected 7 cards in %s, got %d", pile, len(neutral.Piles[pile]))
+		}
+		for _, card := range neutral.Piles[pile] {
+			if card.CardID != 0 {
+				t.Errorf("Hands should be hidden from spectators, got %v", card)
+			}
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	cards := gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Shuffler",
+      "imageSrc": "card2",
+      "effect": { 
+        "kind": "THEN", 
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": { "kind": "TARGET", "targetType": "THIS" },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "SHUFFLE",
+            "pile": "DECK"
+          }
+        ]
+      }
+    }
+  ]`)
+	game := gamemanager.MakeGame(cards)
+
+	game.AddPlayer()
+	game.AddPlayer()
+
+	deck := gamemanager.DeckFromSet("set1", 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.RecordCoinFlip(true, false)
+	game.StartGame(true)
+
+	actions := []struct {
+		user   uint8
+		action gamemanager.Action
+	}{
+		{0, gamemanager.Action{ActionType: gamemanager.ActionTypeEndTurn}},
+		{1, gamemanager.Action{ActionType: gamemanager.ActionTypeEndTurn}},
+		{0, gamemanager.Action{ActionType: gamemanager.ActionTypeConcede}},
+	}
+
+	// play a shuffler whenever one is drawn, so the log has a
+	// shuffle in the middle of the game
+	for _, el := range actions {
+		for _, card := range game.Players[el.user].PlayerPiles[gamemanager.HAND_PILE].Cards {
+			if card.ID == 1 && el.action.ActionType == gamemanager.ActionTypeEndTurn {
+				_, _, err := game.ProcessAction(el.user, &gamemanager.Action{
+					ActionType: gamemanager.ActionTypeSelectCard,
+					SelectedCards: []uint{card.GameID},
+					From: gamemanager.HAND_PILE,
+				})
+				if err != nil {
+					t.Fatalf("Error playing shuffler: %v", err)
+				}
+				break
+			}
+		}
+		_, _, err := game.ProcessAction(el.user, &el.action)
+		if err != nil {
+			t.Fatalf("Error processing action: %v", err)
+		}
+	}
+
+	shuffles := 0
+	for _, event := range game.Log {
+		if event.Kind == gamemanager.EventKindShuffle {
+			shuffles++
+		}
+	}
+	if shuffles < 3 {
+		t.Fatalf("Expected the starting shuffles and a shuffle effect in the log, got %v", game.Log)
+	}
+
+	data, err := json.Marshal(game.Log)
+	if err != nil {
+		t.Fatalf("Error serializing log: %v", err)
+	}
+	var log []gamemanager.Event
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("Error parsing log: %v", err)
+	}
+
+	replayed, err := gamemanager.Replay(cards, log)
+	if err != nil {
+		t.Fatalf("Error replaying log: %v", err)
+	}
+	if !replayed.IsOver() || replayed.Result.Winner != 1 {
+		t.Errorf("Expected replay to end with player 2 winning, got %v", replayed.Result)
+	}
+	for user := uint8(0); user < 2; user++ {
+		expected, _ := json.Marshal(game.GetSnapshot(user))
+		actual, _ := json.Marshal(replayed.GetSnapshot(user))
+		if string(expected) != string(actual) {
+			t.Errorf("Expected replay to end in the same state, got %s, expected %s", actual, expected)
+		}
+	}
+
+	// a log that was tampered with doesn't replay
+	for index, event := range log {
+		if event.Kind == gamemanager.EventKindShuffle {
+			log[index].Seed++
+			break
+		}
+	}
+	if _, err := gamemanager.Replay(cards, log); err == nil {
+		t.Error("Expected replay with a changed seed to diverge")
+	}
+}
+
+func TestFailedAction(t *testing.T) {
+	cards := gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Broken",
+      "imageSrc": "card2",
+      "effect": {
+        "kind": "MOVE",
+        "target": { "kind": "TARGET", "targetType": "THIS" },
+        "to": "NOWHERE"
+      }
+    }
+  ]`)
+	game := gamemanager.MakeGame(cards)
+	game.AddPlayer()
+	game.AddPlayer()
+	game.SetupPlayer(0, gamemanager.DeckFromSet("set1", 1, 1, 1))
+	game.SetupPlayer(1, gamemanager.DeckFromSet("set1", 1, 1, 1))
+	game.StartGame(true)
+
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err == nil || gamemanager.IsGameError(err) {
+		t.Fatalf("Expected the broken card to fail inside the server, got %v", err)
+	}
+
+	gameError := gamemanager.ToGameError(err)
+	if gameError.Code != gamemanager.ErrorCodeInternal || strings.Contains(gameError.Message, "NOWHERE") {
+		t.Errorf("Expected an internal error without the server's details, got %v", gameError)
+	}
+
+	if !game.IsOver() || !game.Result.IsDraw || game.Result.Reason != gamemanager.EndReasonError {
+		t.Fatalf("Expected the failure to end the game, got %v", game.Result)
+	}
+
+	replayed, err := gamemanager.Replay(cards, game.Log)
+	if err != nil {
+		t.Fatalf("Error replaying log: %v", err)
+	}
+	if !replayed.IsOver() || replayed.Result.Reason != gamemanager.EndReasonError {
+		t.Errorf("Expected replay to end the same way, got %v", replayed.Result)
+	}
+}
+
+func TestRejectedAction(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromString(`[
+    {
+      "name": "card 1",
+      "imageSrc": "card1"
+    },
+    {
+      "name": "Clearer",
+      "imageSrc": "card2",
+      "effect": {
+        "kind": "THEN",
+        "args": [
+          {
+            "kind": "MOVE",
+            "target": {
+              "kind": "TARGET",
+              "targetType": "ALL",
+              "filter": { "kind": "JUST", "pile": "HAND" }
+            },
+            "to": "DISCARD"
+          },
+          {
+            "kind": "OR",
+            "args": [
+              {
+                "kind": "MOVE",
+                "target": {
+                  "kind": "TARGET",
+                  "targetType": "SELECT",
+                  "filter": {
+                    "kind": "JUST",
+                    "pile": "HAND",
+                    "count": { "atLeast": 1, "atMost": 1 }
+                  }
+                },
+                "to": "DECK"
+              }
+            ]
+          }
+        ]
+      }
+    }
+  ]`))
+	game.AddPlayer()
+	game.AddPlayer()
+	deck := gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	game.SetupPlayer(0, deck)
+	game.SetupPlayer(1, deck)
+	game.StartGame(true)
+
+	// actions a player can send by mistake are turned away
+	for _, action := range []gamemanager.Action{
+		{ActionType: gamemanager.ActionTypeChooseOption, Option: 0},
+		{ActionType: gamemanager.ActionTypeFinishSelection, SelectedCards: []uint{0}},
+		{ActionType: gamemanager.ActionTypeSelectCard, SelectedCards: []uint{99}, From: gamemanager.HAND_PILE},
+	} {
+		_, _, err := game.ProcessAction(0, &action)
+		if !gamemanager.IsGameError(err) {
+			t.Errorf("Expected %v to be rejected, got %v", action, err)
+		}
+	}
+	if game.IsOver() {
+		t.Fatalf("Expected rejected actions to leave the game running, got %v", game.Result)
+	}
+
+	// the hand is gone by the time the OR is reached, so it does nothing
+	_, _, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{game.Players[0].PlayerPiles[gamemanager.HAND_PILE].Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error processing action: %v", err)
+	}
+	if game.IsOver() || game.CardActionStack != nil {
+		t.Errorf("Expected the effect to finish and the game to go on, got %v", game.Result)
+	}
+	if len(game.Players[0].PlayerPiles[gamemanager.DISCARD_PILE].Cards) != 7 {
+		t.Errorf("Expected the hand to be discarded")
+	}
+}
+
+func TestSeededGame(t *testing.T) {
+	games := make([]*gamemanager.Game, 2)
+	for i := range games {
+		games[i] = gamemanager.MakeGame(gamemanager.SetupFromDirectory(cardInfoPath))
+		games[i].SetSeed(7)
+		games[i].AddPlayer()
+		games[i].AddPlayer()
+
+		deck := gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4)
+		games[i].SetupPlayer(0, deck)
+		games[i].SetupPlayer(1, deck)
+		games[i].StartGame(true)
+	}
+
+	for player := range games[0].Players {
+		for _, pile := range []gamemanager.Pile{gamemanager.HAND_PILE, gamemanager.DECK_PILE} {
+			first := games[0].Players[player].PlayerPiles[pile].Cards
+			second := games[1].Players[player].PlayerPiles[pile].Cards
+			if fmt.Sprint(first) != fmt.Sprint(second) {
+				t.Errorf("Expected the same seed to give the same %s, got %v and %v", pile, first, second)
+			}
+		}
+	}
+
+	for _, event := range games[0].Log {
+		if event.Kind == gamemanager.EventKindStart && event.Seed != 7 {
+			t.Errorf("Expected the seed to be recorded at the start of the game, got %d", event.Seed)
+		}
+	}
+}
+
+func TestCardsFromOtherSets(t *testing.T) {
+	dir := t.TempDir()
+	sets := map[string]string{
+		"set1.json": `[{ "name": "card 1", "imageSrc": "card1" }]`,
+		"set2.json": `[
+			{ "name": "card 1", "imageSrc": "set2card1" },
+			{ "name": "card 2", "imageSrc": "set2card2", "cardType": "EVENT" }
+		]`,
+	}
+	for name, content := range sets {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Error writing set: %v", err)
+		}
+	}
+	cards := gamemanager.SetupFromDirectory(dir)
+
+	data, err := cards.Lookup(gamemanager.CardIdentity{Set: "set2", ID: 1})
+	if err != nil || data.ImageSrc != "set2card2" {
+		t.Errorf("Expected to find set2's second card, got %v, %v", data, err)
+	}
+
+	var gameError *gamemanager.GameError
+	for _, unknown := range []gamemanager.CardIdentity{{Set: "set3", ID: 0}, {Set: "set2", ID: 2}} {
+		_, err := cards.Lookup(unknown)
+		if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeUnknownCard {
+			t.Errorf("Expected %s to be an unknown card, got %v", unknown, err)
+		}
+	}
+
+	// bare numbers, as older clients send, are cards in the default set
+	var decoded []gamemanager.CardIdentity
+	if err := json.Unmarshal([]byte(`[3, {"set": "set2", "id": 1}]`), &decoded); err != nil {
+		t.Fatalf("Error decoding card identities: %v", err)
+	}
+	expectedDecoded := []gamemanager.CardIdentity{{Set: gamemanager.DefaultCardSet, ID: 3}, {Set: "set2", ID: 1}}
+	if fmt.Sprint(decoded) != fmt.Sprint(expectedDecoded) {
+		t.Errorf("Expected %v, got %v", expectedDecoded, decoded)
+	}
+
+	game := gamemanager.MakeGame(cards)
+	game.AddPlayer()
+	game.AddPlayer()
+
+	err = game.SetupPlayer(0, gamemanager.DeckFromSet("set2", 1, 5))
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeUnknownCard {
+		t.Errorf("Expected a deck with an unknown card to be rejected, got %v", err)
+	}
+	if len(game.Players[0].PlayerPiles[gamemanager.DECK_PILE].Cards) != 0 {
+		t.Error("Expected a rejected deck to leave the player without cards")
+	}
+
+	deck := gamemanager.DeckFromSet("set2", 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	if err := game.SetupPlayer(0, deck); err != nil {
+		t.Fatalf("Error setting up player: %v", err)
+	}
+	if err := game.SetupPlayer(1, deck); err != nil {
+		t.Fatalf("Error setting up player: %v", err)
+	}
+	game.StartGame(true)
+
+	hand := game.Players[0].PlayerPiles[gamemanager.HAND_PILE]
+	info, oppInfo, err := game.ProcessAction(0, &gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{hand.Cards[0].GameID},
+		From: gamemanager.HAND_PILE,
+	})
+	if err != nil {
+		t.Fatalf("Error playing card from set2: %v", err)
+	}
+
+	expected := gamemanager.CardIdentity{Set: "set2", ID: 1}
+	if len(info.Movements) != 1 || info.Movements[0].Identity() != expected {
+		t.Errorf("Expected the played card to be %s, got %v", expected, info.Movements)
+	}
+	if len(oppInfo.Movements) != 1 || oppInfo.Movements[0].Identity() != expected {
+		t.Errorf("Expected the opponent to see the played card, got %v", oppInfo.Movements)
+	}
+
+	for _, card := range game.GetSnapshot(1).Piles[gamemanager.OPP_HAND_PILE] {
+		if card.Set != "" || card.CardID != 0 {
+			t.Errorf("Expected the opponent's hand to be hidden, got %v", card)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	cards, err := gamemanager.LoadFromString(`[
+		{
+			"name": "original",
+			"imageSrc": "original",
+			"cardType": "ACTION",
+			"effect": { "kind": "WIN" }
+		},
+		{ "imageSrc": "reprint", "alias": { "set": "set1", "id": 0 } },
+		{ "name": "reprint of reprint", "imageSrc": "reprint2", "cardType": "EVENT", "alias": { "set": "set1", "id": 1 } }
+	]`)
+	if err != nil {
+		t.Fatalf("Error loading cards: %v", err)
+	}
+
+	original, _ := cards.Lookup(gamemanager.CardIdentity{Set: "set1", ID: 0})
+	reprint, _ := cards.Lookup(gamemanager.CardIdentity{Set: "set1", ID: 1})
+	if reprint.Name != "original" || reprint.ImageSrc != "reprint" || reprint.CardType != "ACTION" || reprint.Effect != original.Effect {
+		t.Errorf("Expected the reprint to take everything but its image from the original, got %+v", reprint)
+	}
+	if reprint.Alias != original {
+		t.Error("Expected the reprint's alias to be the original")
+	}
+
+	chained, _ := cards.Lookup(gamemanager.CardIdentity{Set: "set1", ID: 2})
+	if chained.Name != "reprint of reprint" || chained.ImageSrc != "reprint2" || chained.CardType != "EVENT" || chained.Effect != original.Effect {
+		t.Errorf("Expected the chained reprint to keep what it sets and take the rest, got %+v", chained)
+	}
+
+	// the reprint of Ultra Ball in the default cards plays like it
+	ultraBall, _ := gamemanager.SetupFromDirectory(cardInfoPath).Lookup(gamemanager.CardIdentity{Set: "set1", ID: 7})
+	if ultraBall.Effect == nil || ultraBall.PreCondition == nil || ultraBall.CardType != "ACTION" || ultraBall.ImageSrc != "card8" {
+		t.Errorf("Expected the reprint of Ultra Ball to have its effect, got %+v", ultraBall)
+	}
+}
+
+func TestBadAliases(t *testing.T) {
+	cards, err := gamemanager.LoadFromString(`[
+		{ "imageSrc": "card1", "alias": { "set": "set1", "id": 1 } },
+		{ "imageSrc": "card2", "alias": { "set": "set1", "id": 0 } },
+		{ "imageSrc": "card3", "alias": { "set": "set1", "id": 9 } },
+		{ "imageSrc": "card4", "alias": { "set": "set2", "id": 0 } },
+		{ "imageSrc": "card5", "alias": { "set": "set1", "id": 2 } }
+	]`)
+	if err == nil {
+		t.Fatal("Expected bad aliases to be reported")
+	}
+
+	for _, expected := range []string{
+		"alias cycle set1/0 -> set1/1 -> set1/0",
+		"card set1/2 is an alias of set1/9, which doesn't exist",
+		"card set1/3 is an alias of set2/0, which doesn't exist",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q to be reported, got %v", expected, err)
+		}
+	}
+	if strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("Expected each problem to be reported once, got %v", err)
+	}
+
+	// cards with bad aliases are still loaded as they are
+	if card, err := cards.Lookup(gamemanager.CardIdentity{Set: "set1", ID: 4}); err != nil || card.ImageSrc != "card5" {
+		t.Errorf("Expected card with a bad alias chain to still be loaded, got %v, %v", card, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := gamemanager.SetupFromDirectory(cardInfoPath).Validate(); err != nil {
+		t.Errorf("Expected the default cards to be valid, got %v", err)
+	}
+
+	cards, err := gamemanager.LoadFromString(`[
+		{ "imageSrc": "card1", "cardType": "EVNT", "effect": { "kind": "MOOVE" } },
+		{
+			"imageSrc": "card2",
+			"preCondition": { "kind": "OPERATOR", "operator": ">=", "args": [
+				{ "kind": "VARIABLE", "variable": "CARDS_IN_DECK" },
+				{ "kind": "CONSTANT", "val": 1 }
+			] },
+			"effect": { "kind": "THEN", "args": [
+				{ "kind": "SHUFFLE", "pile": "GRAVEYARD" },
+				{
+					"kind": "MOVE",
+					"to": "HAND",
+					"target": { "kind": "TARGET", "targetType": "SELECT", "filter": { "kind": "OR", "args": [
+						{ "kind": "JUST", "pile": "DECK", "type": "SPELL" },
+						{ "kind": "JUST", "pile": "DISCARD", "count": { "atLeast": 3, "atMost": 1 } }
+					] } }
+				}
+			] }
+		},
+		{ "imageSrc": "card3", "alias": { "set": "set1", "id": 0 } },
+		{
+			"imageSrc": "card4",
+			"preCondition": { "kind": "OPERATOR", "operator": ">", "args": [
+				{ "kind": "CONSTANT", "val": 1 }
+			] },
+			"effect": { "kind": "THEN", "args": [
+				{ "kind": "MOVE" },
+				{ "kind": "SHUFFLE" }
+			] }
+		}
+	]`)
+	if err != nil {
+		t.Fatalf("Error loading cards: %v", err)
+	}
+
+	err = cards.Validate()
+	expected := []string{
+		`set1[0].cardType: unknown card type "EVNT"`,
+		`set1[0].effect.kind: unknown effect kind "MOOVE"`,
+		`set1[1].preCondition.operator: unknown operator ">="`,
+		`set1[1].preCondition.args[0].variable: unknown variable "CARDS_IN_DECK"`,
+		`set1[1].effect.args[0].pile: unknown pile "GRAVEYARD"`,
+		`set1[1].effect.args[1].target.filter.args[0].type: unknown card type "SPELL"`,
+		`set1[1].effect.args[1].target.filter.args[1].count: atLeast 3 is more than atMost 1`,
+		`set1[3].preCondition.args: operator ">" takes 2 args, got 1`,
+		`set1[3].effect.args[0].target: move has no target`,
+		`set1[3].effect.args[0].to: move has no pile to move to`,
+		`set1[3].effect.args[1]: shuffle has neither a pile nor a target`,
+	}
+	if err == nil || err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("Expected problems:\n%s\ngot:\n%v", strings.Join(expected, "\n"), err)
+	}
+
+	var validationError *gamemanager.ValidationError
+	if !errors.As(err, &validationError) || validationError.Set != "set1" || validationError.Path != "[0].cardType" {
+		t.Errorf("Expected the problems to be validation errors, got %v", validationError)
+	}
+}
+
+func TestCheckDeck(t *testing.T) {
+	game := gamemanager.MakeGame(gamemanager.SetupFromDirectory(cardInfoPath))
+
+	// anything goes without a format
+	if err := game.CheckDeck(gamemanager.DeckFromSet("set1", 5, 5, 5, 5, 5, 5)); err != nil {
+		t.Errorf("Expected any deck to be legal without a format, got %v", err)
+	}
+
+	game.Rules.DeckFormat = gamemanager.DeckFormat{MaxSize: 5, CopyLimit: 3}
+	if err := game.CheckDeck(gamemanager.DeckFromSet("set1", 5, 5, 7, 0)); err != nil {
+		t.Errorf("Expected deck within the format to be legal, got %v", err)
+	}
+
+	// card 7 is a reprint of Ultra Ball, so counts towards its limit
+	err := game.CheckDeck(gamemanager.DeckFromSet("set1", 5, 5, 7, 7, 0, 0))
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeIllegalDeck {
+		t.Fatalf("Expected illegal deck error, got %v", err)
+	}
+	expected := "deck has 6 cards, more than the maximum of 5; deck has 4 copies of Ultra Ball, more than the limit of 3"
+	if gameError.Message != expected {
+		t.Errorf("Expected %q, got %q", expected, gameError.Message)
+	}
+
+	// cards that don't exist are reported before the format
+	err = game.CheckDeck(gamemanager.DeckFromSet("set1", 5, 5, 7, 7, 99, 99))
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeUnknownCard {
+		t.Fatalf("Expected unknown card error, got %v", err)
+	}
+	expected = `no card 99 in set "set1"`
+	if gameError.Message != expected {
+		t.Errorf("Expected %q, got %q", expected, gameError.Message)
+	}
+}
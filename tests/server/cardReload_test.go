@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zarone/CardGameServer/cmd/gamemanager"
+	"github.com/Zarone/CardGameServer/cmd/server"
+)
+
+// copyTestCards copies the test cards into a directory
+// the test can change, along with any other files given
+func copyTestCards(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	cards, err := os.ReadFile(filepath.Join(cardInfoPath1, "set1.json"))
+	if err != nil {
+		t.Fatalf("Error reading set: %v", err)
+	}
+	writeTestFile(t, dir, "set1.json", string(cards))
+	for name, content := range files {
+		writeTestFile(t, dir, name, content)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing %s: %v", name, err)
+	}
+}
+
+func reloadTestCards(t *testing.T, s *server.Server, token string) (int, server.ReloadResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/admin/reload-cards", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.HandleReloadCards(w, req)
+
+	var response server.ReloadResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestReloadCards(t *testing.T) {
+	dir := copyTestCards(t, nil)
+	s := server.MakeServer(&server.ServerSettings{AdminToken: "secret", StrictCards: true}, dir)
+
+	game := startTestGame(t, s)
+	oldRoom, _ := s.Rooms.Get(game.room)
+
+	writeTestFile(t, dir, "set2.json", `[{ "name": "new card", "imageSrc": "new", "cardType": "EVENT" }]`)
+
+	if status, _ := reloadTestCards(t, s, "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", status)
+	}
+	if s.CardVersion() != 1 {
+		t.Fatalf("Expected a refused reload to keep the cards, got version %d", s.CardVersion())
+	}
+
+	status, response := reloadTestCards(t, s, "secret")
+	if status != http.StatusOK || response.Version != 2 || len(response.Problems) != 0 {
+		t.Fatalf("Expected the cards to be reloaded as version 2, got %d %+v", status, response)
+	}
+
+	// the game in progress keeps the cards it started with
+	newCard := gamemanager.CardIdentity{Set: "set2", ID: 0}
+	if oldRoom.Game.CardHandler.Version != 1 {
+		t.Errorf("Expected the existing room to keep version 1, got %d", oldRoom.Game.CardHandler.Version)
+	}
+	if _, err := oldRoom.Game.CardHandler.Lookup(newCard); err == nil {
+		t.Error("Expected the existing room not to have the new card")
+	}
+
+	// while new rooms get the new cards
+	newRoom, _ := s.Rooms.Get(createTestRoom(t, s, "").ID)
+	if newRoom.Game.CardHandler.Version != 2 {
+		t.Errorf("Expected the new room to have version 2, got %d", newRoom.Game.CardHandler.Version)
+	}
+	if _, err := newRoom.Game.CardHandler.Lookup(newCard); err != nil {
+		t.Errorf("Expected the new room to have the new card, got %v", err)
+	}
+
+	// invalid cards are refused in strict mode, and the last good ones kept
+	writeTestFile(t, dir, "set2.json", `[{ "imageSrc": "new", "effect": { "kind": "MOOVE" } }]`)
+	status, response = reloadTestCards(t, s, "secret")
+	if status != http.StatusUnprocessableEntity || response.Error == "" || response.Version != 2 {
+		t.Errorf("Expected invalid cards to be refused, got %d %+v", status, response)
+	}
+	if s.CardVersion() != 2 {
+		t.Errorf("Expected the cards to stay at version 2, got %d", s.CardVersion())
+	}
+
+	// a file that can't be loaded keeps the last good cards,
+	// even outside strict mode
+	lenient := server.MakeServer(&server.ServerSettings{AdminToken: "secret"}, dir)
+	writeTestFile(t, dir, "set2.json", `[{ "name": "half written`)
+	status, response = reloadTestCards(t, lenient, "secret")
+	if status != http.StatusUnprocessableEntity || response.Error == "" || response.Version != 1 {
+		t.Errorf("Expected a set that can't be loaded to be refused, got %d %+v", status, response)
+	}
+
+	disabled := server.MakeServer(&server.ServerSettings{}, dir)
+	if status, _ := reloadTestCards(t, disabled, ""); status != http.StatusNotFound {
+		t.Errorf("Expected the endpoint to be disabled without an admin token, got %d", status)
+	}
+}
+
+func TestWatchCards(t *testing.T) {
+	dir := copyTestCards(t, nil)
+	s := server.MakeServer(&server.ServerSettings{}, dir)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.WatchCards(10*time.Millisecond, stop)
+
+	// give the watcher time to see the directory as it was
+	time.Sleep(50 * time.Millisecond)
+
+	// a file caught half written isn't loaded
+	writeTestFile(t, dir, "set2.json", `[{ "name": "new card", `)
+	time.Sleep(50 * time.Millisecond)
+	if s.CardVersion() != 1 {
+		t.Fatalf("Expected a set that can't be loaded to keep the cards, got version %d", s.CardVersion())
+	}
+
+	writeTestFile(t, dir, "set2.json", `[{ "name": "new card", "imageSrc": "new" }]`)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.CardVersion() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.CardVersion() != 2 {
+		t.Fatalf("Expected the change to be picked up, got version %d", s.CardVersion())
+	}
+
+	room, _ := s.Rooms.Get(createTestRoom(t, s, "").ID)
+	if _, err := room.Game.CardHandler.Lookup(gamemanager.CardIdentity{Set: "set2", ID: 0}); err != nil {
+		t.Errorf("Expected a new room to have the new card, got %v", err)
+	}
+}
@@ -0,0 +1,619 @@
+package server_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zarone/CardGameServer/cmd/gamemanager"
+	"github.com/Zarone/CardGameServer/cmd/server"
+	"github.com/gorilla/websocket"
+)
+
+// readTestMessage reads the next message, leaving its content to be
+// decoded once the message type is known
+func readTestMessage(t *testing.T, ws *websocket.Conn) server.Message[json.RawMessage] {
+	t.Helper()
+	var msg server.Message[json.RawMessage]
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatalf("Error reading message: %v", err)
+	}
+	return msg
+}
+
+// readTestContent reads the next message and decodes its content,
+// failing if it isn't of the expected type
+func readTestContent[T any](t *testing.T, ws *websocket.Conn, messageType gamemanager.MessageType) T {
+	t.Helper()
+	msg := readTestMessage(t, ws)
+	if msg.MessageType != messageType {
+		t.Fatalf("Expected message of type %d, got %d: %s", messageType, msg.MessageType, string(msg.Content))
+	}
+	var content T
+	if err := json.Unmarshal(msg.Content, &content); err != nil {
+		t.Fatalf("Error decoding message content: %v", err)
+	}
+	return content
+}
+
+func writeTestMessage[T any](t *testing.T, ws *websocket.Conn, messageType gamemanager.MessageType, content T) {
+	t.Helper()
+	err := ws.WriteJSON(server.Message[T]{
+		Content: content,
+		MessageType: messageType,
+		Timestamp: "test",
+	})
+	if err != nil {
+		t.Fatalf("Error writing message: %v", err)
+	}
+}
+
+// dialTestPlayer connects to the test server and reads the greeting,
+// so players are added to the room in the order they're dialed
+func dialTestPlayer(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	_, p, err := ws.ReadMessage()
+	if err != nil || string(p) != "Hi Client!" {
+		t.Fatalf("Did not receive correct init message: %v, %q", err, string(p))
+	}
+	return ws
+}
+
+type testGame struct {
+	url    string
+	// the room the game is in, and the query that selects it
+	room   server.RoomID
+	query  string
+	ws     []*websocket.Conn
+	tokens []string
+	followTokens []string
+	// index of the player going first
+	first  int
+	// the initial game state sent to each player
+	infos  []gamemanager.UpdateInfo
+	// the commitment sent to each player in a provably fair room
+	commitments []string
+}
+
+// startTestGame runs two players through setup in a new room
+func startTestGame(t *testing.T, s *server.Server) *testGame {
+	t.Helper()
+	return startFairTestGame(t, s, nil)
+}
+
+// startFairTestGame runs two players through setup in a provably
+// fair room, sending the given entropy, or in a normal room if
+// there's no entropy
+func startFairTestGame(t *testing.T, s *server.Server, entropy []string) *testGame {
+	t.Helper()
+	url := startTestServer(t, s)
+	room := createTestRoom(t, s, "").ID
+	query := "room=" + string(room)
+
+	ws := []*websocket.Conn{dialTestPlayer(t, url+query), dialTestPlayer(t, url+query)}
+	game := setupTestGame(t, url, ws, entropy)
+	game.room, game.query = room, query
+	return game
+}
+
+// startTestServer serves the server's websocket, returning
+// the URL to add a query to
+func startTestServer(t *testing.T, s *server.Server) string {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleWS))
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?"
+}
+
+// setupTestGame takes two players who have been greeted by the
+// server through setup, the coin flip and the turn order
+func setupTestGame(t *testing.T, url string, ws []*websocket.Conn, entropy []string) *testGame {
+	t.Helper()
+	commitments := make([]string, len(ws))
+	for i := range ws {
+		setup := server.SetupContent{
+			Deck: gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4),
+		}
+		if entropy != nil {
+			commitments[i] = readTestContent[server.CommitmentContent](t, ws[i], gamemanager.MessageTypeCommitment).Commitment
+			setup.Entropy = entropy[i]
+		}
+		writeTestMessage(t, ws[i], gamemanager.MessageTypeSetup, setup)
+	}
+	tokens := make([]string, len(ws))
+	followTokens := make([]string, len(ws))
+	for i := range ws {
+		setupResponse := readTestContent[server.SetupResponse](t, ws[i], gamemanager.MessageTypeSetup)
+		tokens[i] = setupResponse.ReconnectToken
+		followTokens[i] = setupResponse.FollowToken
+	}
+
+	// the first player calls the coin flip
+	readTestContent[server.CoinFlipContent](t, ws[0], gamemanager.MessageTypeHeadsOrTails)
+	readTestContent[server.CoinFlipContent](t, ws[1], gamemanager.MessageTypeHeadsOrTails)
+	writeTestMessage(t, ws[0], gamemanager.MessageTypeCoinChoice, server.CoinFlipContentChoice{Heads: true})
+
+	// whoever won the flip chooses to go first
+	first := -1
+	for i := range ws {
+		msg := readTestMessage(t, ws[i])
+		var content server.StartGameContent
+		json.Unmarshal(msg.Content, &content)
+		if content.IsChoosingTurnOrder {
+			first = i
+		}
+	}
+	if first == -1 {
+		t.Fatal("Neither player was asked to choose the turn order")
+	}
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeFirstOrSecondChoice, server.StartGameContentChoice{First: true})
+
+	infos := make([]gamemanager.UpdateInfo, len(ws))
+	for i := range ws {
+		infos[i] = readTestContent[gamemanager.UpdateInfo](t, ws[i], gamemanager.MessageTypeGameplay)
+		if (i == first) != (infos[i].Phase == gamemanager.PHASE_MY_TURN) {
+			t.Fatalf("Player %d started in phase %d", i+1, infos[i].Phase)
+		}
+	}
+
+	return &testGame{
+		url: url,
+		ws: ws,
+		tokens: tokens,
+		followTokens: followTokens,
+		first: first,
+		infos: infos,
+		commitments: commitments,
+	}
+}
+
+func TestErrorMessagesKeepConnection(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	ws, first, second := game.ws, game.first, 1-game.first
+
+	writeTestMessage(t, ws[second], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	gameError := readTestContent[gamemanager.GameError](t, ws[second], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeNotYourTurn {
+		t.Errorf("Expected not your turn error, got %v", gameError)
+	}
+
+	if err := ws[first].WriteMessage(websocket.TextMessage, []byte("{not json")); err != nil {
+		t.Fatalf("Error writing message: %v", err)
+	}
+	gameError = readTestContent[gamemanager.GameError](t, ws[first], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeMalformedMessage {
+		t.Errorf("Expected malformed message error, got %v", gameError)
+	}
+
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeSelectCard,
+		SelectedCards: []uint{1000},
+		From: gamemanager.HAND_PILE,
+	})
+	gameError = readTestContent[gamemanager.GameError](t, ws[first], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeUnknownCard {
+		t.Errorf("Expected unknown card error, got %v", gameError)
+	}
+
+	// the connection is still usable after the errors
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	info := readTestContent[gamemanager.UpdateInfo](t, ws[first], gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_OPPONENTS_TURN {
+		t.Errorf("Expected turn to end, got phase %d", info.Phase)
+	}
+	info = readTestContent[gamemanager.UpdateInfo](t, ws[second], gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Expected turn to start, got phase %d", info.Phase)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	ws, first, second := game.ws, game.first, 1-game.first
+
+	if game.tokens[0] == "" || game.tokens[0] == game.tokens[1] {
+		t.Fatalf("Expected distinct reconnect tokens, got %v", game.tokens)
+	}
+
+	// the second player drops, and misses the first player's turn ending
+	ws[second].Close()
+
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	readTestContent[gamemanager.UpdateInfo](t, ws[first], gamemanager.MessageTypeGameplay)
+
+	badWS, _, err := websocket.DefaultDialer.Dial(game.url+"reconnect=nope", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer badWS.Close()
+	gameError := readTestContent[gamemanager.GameError](t, badWS, gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeUnknownToken {
+		t.Errorf("Expected unknown token error, got %v", gameError)
+	}
+
+	newWS, _, err := websocket.DefaultDialer.Dial(game.url+"reconnect="+game.tokens[second], nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer newWS.Close()
+
+	snapshot := readTestContent[gamemanager.Snapshot](t, newWS, gamemanager.MessageTypeSnapshot)
+	if snapshot.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Expected it to be the reconnected player's turn, got phase %d", snapshot.Phase)
+	}
+	if len(snapshot.Piles[gamemanager.HAND_PILE]) != 8 || len(snapshot.Piles[gamemanager.DECK_PILE]) != 2 {
+		t.Errorf("Expected the card drawn at the start of the turn to be in hand, got %v", snapshot.Piles)
+	}
+	if len(snapshot.Piles[gamemanager.OPP_HAND_PILE]) != 7 {
+		t.Errorf("Expected 7 cards in the opponent's hand, got %v", snapshot.Piles[gamemanager.OPP_HAND_PILE])
+	}
+	for _, card := range snapshot.Piles[gamemanager.OPP_HAND_PILE] {
+		if card.CardID != 0 {
+			t.Errorf("Opponent's hand was not hidden: %v", card)
+		}
+	}
+	for _, card := range snapshot.Piles[gamemanager.DECK_PILE] {
+		if card.CardID != 0 {
+			t.Errorf("Deck was not hidden: %v", card)
+		}
+	}
+
+	// the game carries on with the new connection
+	writeTestMessage(t, newWS, gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	info := readTestContent[gamemanager.UpdateInfo](t, newWS, gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_OPPONENTS_TURN {
+		t.Errorf("Expected turn to end, got phase %d", info.Phase)
+	}
+	info = readTestContent[gamemanager.UpdateInfo](t, ws[first], gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_MY_TURN {
+		t.Errorf("Expected turn to start, got phase %d", info.Phase)
+	}
+}
+
+func TestLeavingDuringSetup(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	url := startTestServer(t, s) + "room=" + string(createTestRoom(t, s, "").ID)
+	ws := []*websocket.Conn{dialTestPlayer(t, url), dialTestPlayer(t, url)}
+
+	writeTestMessage(t, ws[0], gamemanager.MessageTypeSetup, server.SetupContent{
+		Deck: gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4),
+	})
+	ws[1].Close()
+
+	gameError := readTestContent[gamemanager.GameError](t, ws[0], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeOpponentLeft {
+		t.Errorf("Expected opponent left error, got %v", gameError)
+	}
+}
+
+func TestIllegalDeck(t *testing.T) {
+	dir := copyTestCards(t, map[string]string{
+		"set2.json": `[{ "name": "new card", "imageSrc": "new" }]`,
+		gamemanager.RulesFileName: `{ "deckFormat": { "minSize": 10, "copyLimit": 4, "allowedSets": ["set1"] } }`,
+	})
+
+	s := server.MakeServer(&server.ServerSettings{}, dir)
+	url := startTestServer(t, s)
+	query := "room=" + string(createTestRoom(t, s, "").ID)
+	ws := []*websocket.Conn{dialTestPlayer(t, url+query), dialTestPlayer(t, url+query)}
+
+	// cards that don't exist are reported on their own
+	writeTestMessage(t, ws[1], gamemanager.MessageTypeSetup, server.SetupContent{
+		Deck: gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 99),
+	})
+	gameError := readTestContent[gamemanager.GameError](t, ws[1], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeUnknownCard || gameError.Message != `no card 99 in set "set1"` {
+		t.Errorf("Expected unknown card error, got %v", gameError)
+	}
+
+	// five Ultra Balls and a reprint of it, and a card from another set
+	deck := append(gamemanager.DeckFromSet("set1", 5, 5, 5, 5, 5, 7), gamemanager.CardIdentity{Set: "set2", ID: 0})
+	writeTestMessage(t, ws[1], gamemanager.MessageTypeSetup, server.SetupContent{Deck: deck})
+
+	gameError = readTestContent[gamemanager.GameError](t, ws[1], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeIllegalDeck {
+		t.Errorf("Expected illegal deck error, got %v", gameError)
+	}
+	for _, violation := range []string{
+		"deck has 7 cards, fewer than the minimum of 10",
+		"deck has 6 copies of Ultra Ball, more than the limit of 4",
+		"set2/0 is from set2, which isn't allowed",
+	} {
+		if !strings.Contains(gameError.Message, violation) {
+			t.Errorf("Expected %q to be listed, got %q", violation, gameError.Message)
+		}
+	}
+
+	// the player can send a legal deck instead, and the game goes on
+	setupTestGame(t, url, ws, nil)
+
+	// a player leaving after an illegal deck doesn't leave the other waiting
+	query = "room=" + string(createTestRoom(t, s, "").ID)
+	ws = []*websocket.Conn{dialTestPlayer(t, url+query), dialTestPlayer(t, url+query)}
+	writeTestMessage(t, ws[0], gamemanager.MessageTypeSetup, server.SetupContent{
+		Deck: gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4),
+	})
+	writeTestMessage(t, ws[1], gamemanager.MessageTypeSetup, server.SetupContent{
+		Deck: gamemanager.DeckFromSet("set1", 0, 1, 2),
+	})
+	readTestContent[gamemanager.GameError](t, ws[1], gamemanager.MessageTypeError)
+	ws[1].Close()
+
+	gameError = readTestContent[gamemanager.GameError](t, ws[0], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeOpponentLeft {
+		t.Errorf("Expected opponent left error, got %v", gameError)
+	}
+
+	// a player can't keep sending illegal decks forever
+	query = "room=" + string(createTestRoom(t, s, "").ID)
+	ws = []*websocket.Conn{dialTestPlayer(t, url+query), dialTestPlayer(t, url+query)}
+	writeTestMessage(t, ws[0], gamemanager.MessageTypeSetup, server.SetupContent{
+		Deck: gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4),
+	})
+	for i := 0; i < server.MaxDeckAttempts; i++ {
+		writeTestMessage(t, ws[1], gamemanager.MessageTypeSetup, server.SetupContent{
+			Deck: gamemanager.DeckFromSet("set1", 0, 1, 2),
+		})
+		readTestContent[gamemanager.GameError](t, ws[1], gamemanager.MessageTypeError)
+	}
+
+	gameError = readTestContent[gamemanager.GameError](t, ws[0], gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeOpponentLeft {
+		t.Errorf("Expected opponent left error after too many illegal decks, got %v", gameError)
+	}
+}
+
+func TestSnapshotRequest(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	ws, first := game.ws, game.first
+
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeSnapshot, struct{}{})
+	snapshot := readTestContent[gamemanager.Snapshot](t, ws[first], gamemanager.MessageTypeSnapshot)
+	if snapshot.Phase != gamemanager.PHASE_MY_TURN || len(snapshot.Piles[gamemanager.HAND_PILE]) != 7 {
+		t.Errorf("Unexpected snapshot %v", snapshot)
+	}
+
+	spectator := dialTestPlayer(t, game.url+game.query+"&spectator=true")
+	snapshot = readTestContent[gamemanager.Snapshot](t, spectator, gamemanager.MessageTypeSnapshot)
+	if snapshot.Phase != gamemanager.PHASE_SPECTATING {
+		t.Errorf("Expected spectating phase, got %d", snapshot.Phase)
+	}
+	for _, card := range snapshot.Piles[gamemanager.HAND_PILE] {
+		if card.CardID != 0 {
+			t.Errorf("Hands should be hidden from spectators, got %v", card)
+		}
+	}
+}
+
+func TestSpectatorFeed(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	ws, first, second := game.ws, game.first, 1-game.first
+
+	neutral := dialTestPlayer(t, game.url+game.query+"&spectator=true")
+	readTestContent[gamemanager.Snapshot](t, neutral, gamemanager.MessageTypeSnapshot)
+
+	// following a player without their follow token falls back to
+	// the neutral view, and their reconnect token isn't one
+	if game.followTokens[second] == "" || game.followTokens[second] == game.tokens[second] {
+		t.Fatalf("Expected a follow token apart from the reconnect token, got %q", game.followTokens[second])
+	}
+	for _, token := range []string{"", game.tokens[second]} {
+		stranger := dialTestPlayer(t, game.url+game.query+"&spectator=true&follow="+strconv.Itoa(second)+"&token="+token)
+		gameError := readTestContent[gamemanager.GameError](t, stranger, gamemanager.MessageTypeError)
+		if gameError.Code != gamemanager.ErrorCodeUnknownToken {
+			t.Errorf("Expected following with token %q to be refused, got %v", token, gameError)
+		}
+		snapshot := readTestContent[gamemanager.Snapshot](t, stranger, gamemanager.MessageTypeSnapshot)
+		if snapshot.Phase != gamemanager.PHASE_SPECTATING {
+			t.Errorf("Expected the neutral view, got phase %d", snapshot.Phase)
+		}
+	}
+
+	// nor does the follow token take the player's seat
+	thief, _, err := websocket.DefaultDialer.Dial(game.url+"reconnect="+game.followTokens[second], nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer thief.Close()
+	gameError := readTestContent[gamemanager.GameError](t, thief, gamemanager.MessageTypeError)
+	if gameError.Code != gamemanager.ErrorCodeUnknownToken {
+		t.Errorf("Expected reconnecting with a follow token to be refused, got %v", gameError)
+	}
+
+	follower := dialTestPlayer(t, game.url+game.query+"&spectator=true&follow="+strconv.Itoa(second)+"&token="+game.followTokens[second])
+	snapshot := readTestContent[gamemanager.Snapshot](t, follower, gamemanager.MessageTypeSnapshot)
+	if snapshot.Phase != gamemanager.PHASE_OPPONENTS_TURN {
+		t.Errorf("Expected follower to see the second player's phase, got %d", snapshot.Phase)
+	}
+
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	readTestContent[gamemanager.UpdateInfo](t, ws[first], gamemanager.MessageTypeGameplay)
+	secondInfo := readTestContent[gamemanager.UpdateInfo](t, ws[second], gamemanager.MessageTypeGameplay)
+
+	// the second player's draw is hidden from the neutral view
+	info := readTestContent[gamemanager.UpdateInfo](t, neutral, gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_SPECTATING || len(info.SelectableCards) != 0 {
+		t.Errorf("Expected spectating phase, got %v", info)
+	}
+	if len(info.Movements) != 1 || info.Movements[0].CardID != 0 {
+		t.Errorf("Expected one hidden draw, got %v", info.Movements)
+	}
+
+	info = readTestContent[gamemanager.UpdateInfo](t, follower, gamemanager.MessageTypeGameplay)
+	if !reflect.DeepEqual(info, secondInfo) {
+		t.Errorf("Expected follower to get the second player's update %v, got %v", secondInfo, info)
+	}
+}
+
+func TestDelayedSpectator(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{SpectatorDelayActions: 1}, cardInfoPath1)
+	game := startTestGame(t, s)
+	ws, first, second := game.ws, game.first, 1-game.first
+
+	delayed := dialTestPlayer(t, game.url+game.query+"&spectator=true&delayed=true")
+
+	endTurn := func(player int) gamemanager.UpdateInfo {
+		writeTestMessage(t, ws[player], gamemanager.MessageTypeGameplay, gamemanager.Action{
+			ActionType: gamemanager.ActionTypeEndTurn,
+		})
+		readTestContent[gamemanager.UpdateInfo](t, ws[player], gamemanager.MessageTypeGameplay)
+		return readTestContent[gamemanager.UpdateInfo](t, ws[1-player], gamemanager.MessageTypeGameplay)
+	}
+
+	// the start of the game is held back until an action comes after it
+	secondInfo := endTurn(first)
+	snapshot := readTestContent[gamemanager.Snapshot](t, delayed, gamemanager.MessageTypeSnapshot)
+	if snapshot.TurnNumber != 1 {
+		t.Errorf("Expected the snapshot from the start of the game, got turn %d", snapshot.TurnNumber)
+	}
+	for _, pile := range []gamemanager.Pile{gamemanager.HAND_PILE, gamemanager.OPP_HAND_PILE} {
+		hidden := 0
+		for _, card := range snapshot.Piles[pile] {
+			if card.CardID == 0 {
+				hidden++
+			}
+		}
+		if len(snapshot.Piles[pile]) != 7 || hidden == 7 {
+			t.Errorf("Expected both hands to be shown, got %v", snapshot.Piles[pile])
+		}
+	}
+	// while the order of the decks stays hidden
+	for _, pile := range []gamemanager.Pile{gamemanager.DECK_PILE, gamemanager.OPP_DECK_PILE} {
+		for _, card := range snapshot.Piles[pile] {
+			if card.CardID != 0 {
+				t.Errorf("Expected the decks to be hidden, got %v", snapshot.Piles[pile])
+				break
+			}
+		}
+	}
+
+	delayed.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, p, err := delayed.ReadMessage(); err == nil {
+		t.Fatalf("Expected the first turn to be held back, got %s", string(p))
+	}
+	delayed.Close()
+
+	// a spectator joining later catches up to the same point
+	delayed = dialTestPlayer(t, game.url+game.query+"&spectator=true&delayed=true")
+	readTestContent[gamemanager.Snapshot](t, delayed, gamemanager.MessageTypeSnapshot)
+
+	endTurn(second)
+	info := readTestContent[gamemanager.UpdateInfo](t, delayed, gamemanager.MessageTypeGameplay)
+	if info.Phase != gamemanager.PHASE_SPECTATING || len(info.Movements) != 1 {
+		t.Fatalf("Expected the second player's draw, got %v", info)
+	}
+	drawn, seen := info.Movements[0], secondInfo.Movements[0]
+	if drawn.GameID != seen.GameID || drawn.CardID != seen.CardID {
+		t.Errorf("Expected the second player's draw to be shown, got %v, they saw %v", drawn, seen)
+	}
+
+	// once the game is over, everything held back is released
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeConcede,
+	})
+	readTestContent[gamemanager.UpdateInfo](t, delayed, gamemanager.MessageTypeGameplay)
+	readTestContent[gamemanager.UpdateInfo](t, delayed, gamemanager.MessageTypeGameplay)
+	gameOver := readTestContent[server.GameOverContent](t, delayed, gamemanager.MessageTypeGameOver)
+	if gameOver.Winner != second || gameOver.Reason != gamemanager.EndReasonConcession {
+		t.Errorf("Expected the second player to win by concession, got %v", gameOver)
+	}
+}
+
+func TestTimedDelayedSpectator(t *testing.T) {
+	delay := 200 * time.Millisecond
+	s := server.MakeServer(&server.ServerSettings{SpectatorDelay: delay}, cardInfoPath1)
+	game := startTestGame(t, s)
+
+	joined := time.Now()
+	delayed := dialTestPlayer(t, game.url+game.query+"&spectator=true&delayed=true")
+	readTestContent[gamemanager.Snapshot](t, delayed, gamemanager.MessageTypeSnapshot)
+	if time.Since(joined) < delay/2 {
+		t.Errorf("Expected the start of the game to be held back, got it after %s", time.Since(joined))
+	}
+}
+
+func TestSeededServer(t *testing.T) {
+	games := make([]*testGame, 2)
+	for i := range games {
+		s := server.MakeServer(&server.ServerSettings{Seed: 42}, cardInfoPath1)
+		games[i] = startTestGame(t, s)
+	}
+
+	if games[0].first != games[1].first {
+		t.Errorf("Expected the same seed to give the same coin flip")
+	}
+	if !reflect.DeepEqual(games[0].infos, games[1].infos) {
+		t.Errorf("Expected the same seed to give the same hands, got %v and %v", games[0].infos, games[1].infos)
+	}
+}
+
+func TestProvablyFair(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{ProvablyFair: true}, cardInfoPath1)
+	entropy := []string{"first player's entropy", "second player's entropy"}
+	game := startFairTestGame(t, s, entropy)
+	ws, first := game.ws, game.first
+
+	if game.commitments[0] == "" || game.commitments[0] != game.commitments[1] {
+		t.Fatalf("Expected both players to get the same commitment, got %v", game.commitments)
+	}
+
+	writeTestMessage(t, ws[first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeConcede,
+	})
+	readTestContent[gamemanager.UpdateInfo](t, ws[first], gamemanager.MessageTypeGameplay)
+	gameOver := readTestContent[server.GameOverContent](t, ws[first], gamemanager.MessageTypeGameOver)
+
+	seed, err := server.VerifyFairSeed(gameOver.ServerSeed, game.commitments[0], entropy)
+	if err != nil {
+		t.Fatalf("Error verifying server seed: %v", err)
+	}
+	if _, err := server.VerifyFairSeed(gameOver.ServerSeed, strings.Repeat("0", 64), entropy); err == nil {
+		t.Error("Expected a different commitment not to verify")
+	}
+
+	// rebuild the coin flip and the shuffles from the seed, as Room.SetSeed does.
+	// The first player called heads, and whoever called it chose to go first
+	rng := rand.New(rand.NewSource(seed))
+	replayed := gamemanager.MakeGame(gamemanager.SetupFromDirectory(cardInfoPath1))
+	replayed.SetSeed(rng.Int63())
+	isHeads := rng.Intn(2) == 1
+	if isHeads != (first == 0) {
+		t.Errorf("Expected the coin flip to land on heads: %t, but player %d went first", isHeads, first+1)
+	}
+
+	replayed.AddPlayer()
+	replayed.AddPlayer()
+	replayed.SetupPlayer(0, gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4))
+	replayed.SetupPlayer(1, gamemanager.DeckFromSet("set1", 0, 1, 2, 3, 4, 0, 1, 2, 3, 4))
+	info, _ := replayed.StartGame(first == 0)
+	if !reflect.DeepEqual(info.Movements, game.infos[0].Movements) {
+		t.Errorf("Expected the seed to give the same draws, got %v, expected %v", info.Movements, game.infos[0].Movements)
+	}
+}
@@ -0,0 +1,174 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zarone/CardGameServer/cmd/gamemanager"
+	"github.com/Zarone/CardGameServer/cmd/server"
+)
+
+func createTestRoom(t *testing.T, s *server.Server, options string) server.JoinResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.HandleCreateRoom(w, httptest.NewRequest("POST", "/api/rooms/create", strings.NewReader(options)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var response server.JoinResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return response
+}
+
+func TestLobby(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+
+	public := createTestRoom(t, s, `{"provablyFair": true}`)
+	private := createTestRoom(t, s, `{"private": true}`)
+	if public.ID == "" || public.ID == private.ID {
+		t.Fatalf("Expected distinct room IDs, got %s and %s", public.ID, private.ID)
+	}
+	if public.SocketPath != "/socket?room="+string(public.ID) {
+		t.Errorf("Unexpected socket path %s", public.SocketPath)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleOpenRooms(w, httptest.NewRequest("GET", "/api/rooms/open", nil))
+	var openRooms []server.OpenRoom
+	if err := json.Unmarshal(w.Body.Bytes(), &openRooms); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(openRooms) != 1 || openRooms[0].ID != public.ID || !openRooms[0].ProvablyFair {
+		t.Errorf("Expected only the public room to be open, got %v", openRooms)
+	}
+
+	for _, el := range []struct {
+		id     server.RoomID
+		status int
+	}{
+		{public.ID, http.StatusOK},
+		{private.ID, http.StatusOK},
+		{"nope", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		s.HandleJoinRoom(w, httptest.NewRequest("GET", "/api/rooms/join?id="+string(el.id), nil))
+		if w.Code != el.status {
+			t.Errorf("Expected status code %d joining %s, got %d", el.status, el.id, w.Code)
+		}
+	}
+
+	// rooms are only made through the lobby
+	req := httptest.NewRequest("GET", "/ws?room=200", nil)
+	_, err := s.AddToRoom(req, &server.User{IsSpectator: true})
+	var gameError *gamemanager.GameError
+	if !errors.As(err, &gameError) || gameError.Code != gamemanager.ErrorCodeUnknownRoom {
+		t.Errorf("Expected joining a room nobody made to fail, got %v", err)
+	}
+	if _, ok := s.Rooms.Get("200"); ok {
+		t.Error("Expected no room to be made by joining it")
+	}
+}
+
+func TestIdleRoomsAreCollected(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{IdleRoomTimeout: 50 * time.Millisecond}, cardInfoPath1)
+
+	created := createTestRoom(t, s, "")
+	if _, ok := s.Rooms.Get(created.ID); !ok {
+		t.Fatal("Expected created room to be registered")
+	}
+
+	// rooms with someone in them aren't idle
+	occupied := createTestRoom(t, s, "")
+	req := httptest.NewRequest("GET", "/ws?room="+string(occupied.ID), nil)
+	s.AddToRoom(req, &server.User{IsSpectator: true})
+
+	// and neither are games in progress, which the
+	// players can still reconnect to
+	game := startTestGame(t, s)
+	for _, ws := range game.ws {
+		ws.Close()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	s.Rooms.List()
+
+	if _, ok := s.Rooms.Get(created.ID); ok {
+		t.Error("Expected idle room to be removed")
+	}
+	if _, ok := s.Rooms.Get(occupied.ID); !ok {
+		t.Error("Expected occupied room to be kept")
+	}
+	if _, ok := s.Rooms.Get(game.room); !ok {
+		t.Error("Expected game in progress to be kept")
+	}
+
+	// at least for a while, if nobody comes back
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.Rooms.List()
+		if _, ok := s.Rooms.Get(game.room); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected abandoned game in progress to be removed eventually")
+}
+
+func TestJoiningGameInProgress(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	room, _ := s.Rooms.Get(game.room)
+
+	// a seat left by a player who dropped is still theirs
+	game.ws[0].Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for room.GetPlayersInRoom() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleJoinRoom(w, httptest.NewRequest("GET", "/api/rooms/join?id="+string(game.room), nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a game in progress not to be joinable, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/ws?"+game.query, nil)
+	if _, err := s.AddToRoom(req, &server.User{}); err == nil {
+		t.Error("Expected joining a game in progress as a player to fail")
+	}
+	if room.GetPlayersInRoom() != 1 {
+		t.Errorf("Expected the player not to be added, got %d players", room.GetPlayersInRoom())
+	}
+}
+
+func TestFinishedRoomsAreCollected(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+
+	writeTestMessage(t, game.ws[0], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeConcede,
+	})
+	for _, ws := range game.ws {
+		readTestContent[gamemanager.UpdateInfo](t, ws, gamemanager.MessageTypeGameplay)
+		readTestContent[server.GameOverContent](t, ws, gamemanager.MessageTypeGameOver)
+		ws.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.Rooms.List()
+		if _, ok := s.Rooms.Get(game.room); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected finished room to be removed once everyone left")
+}
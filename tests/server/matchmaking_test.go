@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zarone/CardGameServer/cmd/server"
+	"github.com/gorilla/websocket"
+)
+
+// waitForQueue waits until the given number of players are
+// waiting for a match
+func waitForQueue(t *testing.T, s *server.Server, waiting int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.PlayersWaitingForMatch() != waiting {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d players waiting, got %d", waiting, s.PlayersWaitingForMatch())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func dialMatchmaking(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func readGreeting(t *testing.T, ws *websocket.Conn) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, p, err := ws.ReadMessage()
+	if err != nil || string(p) != "Hi Client!" {
+		t.Fatalf("Did not receive correct init message: %v, %q", err, string(p))
+	}
+}
+
+func TestMatchmaking(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleMatchmake))
+	t.Cleanup(ts.Close)
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/matchmake?"
+
+	first := dialMatchmaking(t, url+"sets=set2,set1&rating=1500")
+	waitForQueue(t, s, 1)
+
+	// neither of these can play the first player, or each other
+	dialMatchmaking(t, url+"sets=set3")
+	dialMatchmaking(t, url+"sets=set4&rating=1900")
+	waitForQueue(t, s, 3)
+
+	second := dialMatchmaking(t, url+"sets=set1,set2&rating=1600")
+	readGreeting(t, first)
+	readGreeting(t, second)
+	waitForQueue(t, s, 2)
+
+	// the matched players go through the usual setup, with the
+	// player who waited longest as the first player
+	setupTestGame(t, url, []*websocket.Conn{first, second}, nil)
+
+	rooms := s.Rooms.List()
+	if len(rooms) != 1 || rooms[0].GetPlayersInRoom() != 2 || !rooms[0].Options.Private {
+		t.Errorf("Expected the players to be in one private room, got %v", rooms)
+	}
+}
+
+func TestLeavingMatchmaking(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	ts := httptest.NewServer(http.HandlerFunc(s.HandleMatchmake))
+	t.Cleanup(ts.Close)
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/matchmake?"
+
+	// a player who leaves is taken out of the queue without
+	// waiting for a ping to fail
+	leaving := dialMatchmaking(t, url)
+	waitForQueue(t, s, 1)
+	leaving.Close()
+	waitForQueue(t, s, 0)
+
+	// so the next two players are matched with each other
+	first := dialMatchmaking(t, url)
+	waitForQueue(t, s, 1)
+	second := dialMatchmaking(t, url)
+	readGreeting(t, first)
+	readGreeting(t, second)
+}
@@ -1,9 +1,12 @@
 package server_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -26,17 +29,41 @@ func TestMakeServer(t *testing.T) {
 		t.Error("Server rooms map is nil")
 	}
 	
-	if len(s.Rooms) != 0 {
+	if s.Rooms.Len() != 0 {
 		t.Error("Server rooms map should be empty on creation")
 	}
 }
 
+func TestNewServerStrictCards(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "set1.json"), []byte(`[
+		{ "imageSrc": "card1", "effect": { "kind": "MOOVE" } }
+	]`), 0o644)
+	if err != nil {
+		t.Fatalf("Error writing set: %v", err)
+	}
+
+	if _, err := server.NewServer(&server.ServerSettings{}, dir); err != nil {
+		t.Errorf("Expected invalid cards to only be logged outside strict mode, got %v", err)
+	}
+
+	_, err = server.NewServer(&server.ServerSettings{StrictCards: true}, dir)
+	if err == nil || !strings.Contains(err.Error(), `set1[0].effect.kind: unknown effect kind "MOOVE"`) {
+		t.Errorf("Expected strict mode to refuse invalid cards, got %v", err)
+	}
+
+	if _, err := server.NewServer(&server.ServerSettings{StrictCards: true}, cardInfoPath1); err != nil {
+		t.Errorf("Expected valid cards to pass in strict mode, got %v", err)
+	}
+}
+
 func TestServerAddToRoom(t *testing.T) {
 	settings := &server.ServerSettings{}
 	s := server.MakeServer(settings, cardInfoPath1)
+	id := createTestRoom(t, s, "").ID
 	
 	// Create a test request
-	req := httptest.NewRequest("GET", "/ws?room=1", nil)
+	req := httptest.NewRequest("GET", "/ws?room="+string(id), nil)
 	user := &server.User{
 		IsSpectator: false,
 	}
@@ -48,11 +75,11 @@ func TestServerAddToRoom(t *testing.T) {
 	}
 	
 	if room == nil {
-		t.Error("addToRoom returned nil room")
+		t.Fatal("addToRoom returned nil room")
 	}
 	
-	if room.RoomNumber != 1 {
-		t.Errorf("Expected room number 1, got %d", room.RoomNumber)
+	if room.ID != id {
+		t.Errorf("Expected room %s, got %s", id, room.ID)
 	}
 	
 	// Test adding to full room
@@ -72,7 +99,7 @@ func TestServerRemoveUserFromRoom(t *testing.T) {
 	s := server.MakeServer(settings, cardInfoPath1)
 	
 	// Create a test request
-	req := httptest.NewRequest("GET", "/ws?room=1", nil)
+	req := httptest.NewRequest("GET", "/ws?room="+string(createTestRoom(t, s, "").ID), nil)
 	user := &server.User{
 		IsSpectator: false,
 	}
@@ -91,11 +118,11 @@ func TestServerHandleRoomsAPI(t *testing.T) {
 	s := server.MakeServer(settings, cardInfoPath1)
 	
 	// Add some test rooms
-	req1 := httptest.NewRequest("GET", "/ws?room=1", nil)
+	req1 := httptest.NewRequest("GET", "/ws?room="+string(createTestRoom(t, s, "").ID), nil)
 	user1 := &server.User{IsSpectator: false}
 	s.AddToRoom(req1, user1)
 	
-	req2 := httptest.NewRequest("GET", "/ws?room=2", nil)
+	req2 := httptest.NewRequest("GET", "/ws?room="+string(createTestRoom(t, s, "").ID), nil)
 	user2 := &server.User{IsSpectator: true}
 	s.AddToRoom(req2, user2)
 	
@@ -114,6 +141,69 @@ func TestServerHandleRoomsAPI(t *testing.T) {
 	}
 }
 
+func TestServerHandleRoomsJSON(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+
+	first, second := createTestRoom(t, s, "").ID, createTestRoom(t, s, "").ID
+	s.AddToRoom(httptest.NewRequest("GET", "/ws?room="+string(first), nil), &server.User{IsSpectator: false})
+	s.AddToRoom(httptest.NewRequest("GET", "/ws?room="+string(second), nil), &server.User{IsSpectator: true})
+
+	// private rooms aren't listed
+	private := createTestRoom(t, s, `{"private": true}`).ID
+
+	w := httptest.NewRecorder()
+	s.HandleRoomsJSON(w, httptest.NewRequest("GET", "/api/rooms.json", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var rooms []server.RoomSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &rooms); err != nil {
+		t.Fatalf("Error decoding rooms: %v", err)
+	}
+	if len(rooms) != 2 || rooms[0].ID != first || rooms[1].ID != second {
+		t.Fatalf("Expected rooms %s and %s oldest first, got %v", first, second, rooms)
+	}
+	if rooms[0].Players != 1 || rooms[0].Spectators != 0 || rooms[1].Players != 0 || rooms[1].Spectators != 1 {
+		t.Errorf("Unexpected player and spectator counts %v", rooms)
+	}
+	if rooms[0].Description != server.DESC_JUST_CREATED || rooms[0].TurnNumber != 0 || rooms[0].ActivePlayer != -1 {
+		t.Errorf("Expected room that hasn't started, got %v", rooms[0])
+	}
+
+	// the HTML listing shows the same rooms
+	w = httptest.NewRecorder()
+	s.HandleRoomsAPI(w, httptest.NewRequest("GET", "/api/rooms", nil))
+	body := w.Body.String()
+	if strings.Contains(body, string(private)) {
+		t.Errorf("Expected private room to be left out of HTML listing, got %s", body)
+	}
+	if !strings.Contains(body, "Room "+string(first)+" (Just Created...)") || !strings.Contains(body, "Spectator (Active)") {
+		t.Errorf("Expected rooms in HTML listing, got %s", body)
+	}
+}
+
+func TestRoomSummaryFollowsTheGame(t *testing.T) {
+	s := server.MakeServer(&server.ServerSettings{}, cardInfoPath1)
+	game := startTestGame(t, s)
+	room, _ := s.Rooms.Get(game.room)
+
+	writeTestMessage(t, game.ws[game.first], gamemanager.MessageTypeGameplay, gamemanager.Action{
+		ActionType: gamemanager.ActionTypeEndTurn,
+	})
+	readTestContent[gamemanager.UpdateInfo](t, game.ws[game.first], gamemanager.MessageTypeGameplay)
+
+	w := httptest.NewRecorder()
+	s.HandleRoomsJSON(w, httptest.NewRequest("GET", "/api/rooms.json", nil))
+	var rooms []server.RoomSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &rooms); err != nil {
+		t.Fatalf("Error decoding rooms: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].ID != room.ID || rooms[0].TurnNumber != 2 || rooms[0].ActivePlayer != 1-game.first {
+		t.Errorf("Expected the second turn to be listed, got %v", rooms)
+	}
+}
+
 func TestServerJoin(t *testing.T) {
 	for i := range 10 {
 		t.Run(fmt.Sprintf("TestServerJoin, run-%d", i), func(t *testing.T) {
@@ -122,7 +212,7 @@ func TestServerJoin(t *testing.T) {
       ts := httptest.NewServer(http.HandlerFunc(s.HandleWS))
       defer ts.Close()
 
-      wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=1"
+      wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=" + string(createTestRoom(t, s, "").ID)
 
       // Connect both players
       ws := make([]*websocket.Conn, 2)
@@ -180,7 +270,8 @@ func SimulateSetupPhase(t *testing.T, actions []SetupPhaseAction) (*server.Room,
 	ts := httptest.NewServer(http.HandlerFunc(s.HandleWS))
 	defer ts.Close()
 
-	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=1"
+	id := createTestRoom(t, s, "").ID
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=" + string(id)
 
 	// Connect both players
 	ws := make([]*websocket.Conn, 2)
@@ -200,12 +291,12 @@ func SimulateSetupPhase(t *testing.T, actions []SetupPhaseAction) (*server.Room,
 	// Prepare messages
 	setupMsg := []server.Message[server.SetupContent]{
 		{
-			Content:      server.SetupContent{Deck: []uint{1, 1, 1, 1, 2, 2, 2, 2, 3, 3}},
+			Content:      server.SetupContent{Deck: gamemanager.DeckFromSet("set1", 1, 1, 1, 1, 2, 2, 2, 2, 3, 3)},
 			MessageType:  gamemanager.MessageTypeSetup,
 			Timestamp:    "test",
 		},
 		{
-			Content:      server.SetupContent{Deck: []uint{3, 3, 4, 4, 4, 4, 5, 5, 5, 5}},
+			Content:      server.SetupContent{Deck: gamemanager.DeckFromSet("set1", 3, 3, 4, 4, 4, 4, 5, 5, 5, 5)},
 			MessageType:  gamemanager.MessageTypeSetup,
 			Timestamp:    "test",
 		},
@@ -324,7 +415,8 @@ func SimulateSetupPhase(t *testing.T, actions []SetupPhaseAction) (*server.Room,
 		}
 	}
 
-	return s.Rooms[1], ws[0], ws[1]
+	room, _ := s.Rooms.Get(id)
+	return room, ws[0], ws[1]
 }
 
 // Test permutation (deck1, deck2)
@@ -335,8 +427,8 @@ func TestSetupRace_Deck1Deck2(t *testing.T) {
 				{Player: 1, Type: "deck"},
 				{Player: 2, Type: "deck"},
 			})
-			if r.RoomDescription != server.DESC_FINISHED_INITIALIZATION {
-				t.Fatalf("Room description was %v", r.RoomDescription)
+			if r.Description() != server.DESC_FINISHED_INITIALIZATION {
+				t.Fatalf("Room description was %v", r.Description())
 			}
 		})
 	}
@@ -351,8 +443,8 @@ func TestSetupRace_Deck1Deck2Coin1(t *testing.T) {
 				{Player: 2, Type: "deck"},
 				{Player: 1, Type: "coin"},
 			})
-			if r.RoomDescription != server.DESC_HEADS_OR_TAILS_CHOSEN {
-				t.Fatalf("Room description was %v", r.RoomDescription)
+			if r.Description() != server.DESC_HEADS_OR_TAILS_CHOSEN {
+				t.Fatalf("Room description was %v", r.Description())
 			}
 		})
 	}
@@ -370,8 +462,8 @@ func TestSetupRace_Deck1Deck2Coin1Coin2Turn1Turn2(t *testing.T) {
 				{Player: 1, Type: "turn"},
 				{Player: 2, Type: "turn"},
 			})
-			if r.RoomDescription != server.DESC_INITIAL_STATE_TO_CLIENT {
-				t.Fatalf("Room description was %v", r.RoomDescription)
+			if r.Description() != server.DESC_INITIAL_STATE_TO_CLIENT {
+				t.Fatalf("Room description was %v", r.Description())
 			}
 		})
 	}